@@ -0,0 +1,83 @@
+// fake-clamd 는 개발/테스트용 clamd 대역이다.
+// PING과 INSTREAM 명령만 지원하며, EICAR 테스트 문자열이 포함된 파일을 감염으로 판정한다.
+//
+//	go run ./cmd/fake-clamd -addr :3310
+//	SCAN_MODE=clamav CLAMD_ADDRESS=tcp://localhost:3310 go run .
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io"
+	"log"
+	"net"
+)
+
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+func main() {
+	addr := flag.String("addr", ":3310", "listen address")
+	maxSize := flag.Int("max-size", 512<<20, "StreamMaxLength in bytes")
+	flag.Parse()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	log.Printf("fake clamd listening on %s", *addr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Accept failed: %v", err)
+			continue
+		}
+		go handle(conn, *maxSize)
+	}
+}
+
+func handle(conn net.Conn, maxSize int) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+
+	case "zINSTREAM\x00":
+		// 청크 경계에 걸친 시그니처도 찾을 수 있도록 전체를 모은다
+		var data bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if data.Len()+int(size) > maxSize {
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				return
+			}
+			if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+				return
+			}
+		}
+
+		if bytes.Contains(data.Bytes(), eicar) {
+			conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		} else {
+			conn.Write([]byte("stream: OK\x00"))
+		}
+
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
@@ -0,0 +1,125 @@
+// migrate-storage 는 DB BYTEA 컬럼에 남아 있는 파일을 스토리지 백엔드로 옮긴다.
+//
+//	go run ./cmd/migrate-storage [-dry-run] [-limit N]
+//
+// 한 번에 한 행씩 읽어 옮기고 바로 커밋하므로 중간에 중단해도 다시 실행하면 이어서 진행된다.
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"messenger/config"
+	"messenger/storage"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "count rows to migrate without moving anything")
+	limit := flag.Int("limit", 0, "stop after migrating this many rows per table (0 = no limit)")
+	flag.Parse()
+
+	if err := config.Load(); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	defer config.DB.Close()
+
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	if *dryRun {
+		var files, profiles int
+		config.DB.QueryRow("SELECT COUNT(*) FROM message_files WHERE storage_key IS NULL AND file_data IS NOT NULL").Scan(&files)
+		config.DB.QueryRow("SELECT COUNT(*) FROM users WHERE profile_image_key IS NULL AND profile_image IS NOT NULL").Scan(&profiles)
+		log.Printf("Would migrate %d message files and %d profile images", files, profiles)
+		return
+	}
+
+	files := migrateMessageFiles(*limit)
+	profiles := migrateProfileImages(*limit)
+	log.Printf("Migrated %d message files and %d profile images", files, profiles)
+}
+
+func migrateMessageFiles(limit int) int {
+	ctx := context.Background()
+	migrated := 0
+
+	for limit == 0 || migrated < limit {
+		var id int
+		var mimeType string
+		var data []byte
+		err := config.DB.QueryRow(`
+			SELECT id, mime_type, file_data FROM message_files
+			WHERE storage_key IS NULL AND file_data IS NOT NULL
+			ORDER BY id LIMIT 1
+		`).Scan(&id, &mimeType, &data)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Failed to read message file: %v", err)
+		}
+
+		key := storage.NewKey("files")
+		size, err := storage.Default.Put(ctx, key, bytes.NewReader(data), mimeType)
+		if err != nil {
+			log.Fatalf("Failed to upload message file %d: %v", id, err)
+		}
+
+		_, err = config.DB.Exec(`
+			UPDATE message_files SET storage_key = $1, file_size = $2, file_data = NULL WHERE id = $3
+		`, key, size, id)
+		if err != nil {
+			storage.Default.Delete(ctx, key)
+			log.Fatalf("Failed to update message file %d: %v", id, err)
+		}
+
+		migrated++
+		if migrated%100 == 0 {
+			log.Printf("Migrated %d message files", migrated)
+		}
+	}
+
+	return migrated
+}
+
+func migrateProfileImages(limit int) int {
+	ctx := context.Background()
+	migrated := 0
+
+	for limit == 0 || migrated < limit {
+		var id int
+		var mimeType sql.NullString
+		var data []byte
+		err := config.DB.QueryRow(`
+			SELECT id, profile_image_mime, profile_image FROM users
+			WHERE profile_image_key IS NULL AND profile_image IS NOT NULL
+			ORDER BY id LIMIT 1
+		`).Scan(&id, &mimeType, &data)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Failed to read profile image: %v", err)
+		}
+
+		key := storage.NewKey("profiles")
+		if _, err := storage.Default.Put(ctx, key, bytes.NewReader(data), mimeType.String); err != nil {
+			log.Fatalf("Failed to upload profile image of user %d: %v", id, err)
+		}
+
+		_, err = config.DB.Exec(`
+			UPDATE users SET profile_image_key = $1, profile_image = NULL WHERE id = $2
+		`, key, id)
+		if err != nil {
+			storage.Default.Delete(ctx, key)
+			log.Fatalf("Failed to update profile image of user %d: %v", id, err)
+		}
+
+		migrated++
+	}
+
+	return migrated
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strconv"
 
 	_ "github.com/lib/pq"
 )
@@ -16,6 +17,52 @@ type Config struct {
 	DBName     string
 	JWTSecret  string
 	SMSMode    string
+
+	MaxPinnedMessages int
+
+	PushMode           string
+	FCMProjectID       string
+	FCMCredentialsFile string
+	APNsKeyFile        string
+	APNsKeyID          string
+	APNsTeamID         string
+	APNsTopic          string
+	APNsProduction     bool
+
+	StorageDriver   string
+	StorageLocalDir string
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3UsePathStyle  bool
+
+	SignedURLTTLSeconds int
+
+	MaxImageUploadMB int
+	MaxVideoUploadMB int
+	MaxAudioUploadMB int
+	MaxFileUploadMB  int
+
+	UploadTempDir     string
+	UploadExpiryHours int
+	// 사용자별로 동시에 열어 둘 수 있는 미완료 업로드 수와 선언한 크기 합계
+	MaxPendingUploads  int
+	MaxPendingUploadMB int
+
+	ScanMode                string
+	ClamdAddress            string
+	ScanTimeoutSeconds      int
+	QuarantineRetentionDays int
+
+	MaxSyncedContacts     int
+	ContactSyncDailyLimit int
+
+	UsernameChangeCooldownDays int
+	UsernameReservationDays    int
+
+	AccountDeletionGraceDays int
 }
 
 var AppConfig Config
@@ -30,6 +77,52 @@ func Load() error {
 		DBName:     getEnv("DB_NAME", "messenger"),
 		JWTSecret:  getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
 		SMSMode:    getEnv("SMS_MODE", "mock"),
+
+		MaxPinnedMessages: getEnvInt("MAX_PINNED_MESSAGES", 5),
+
+		PushMode:           getEnv("PUSH_MODE", "mock"),
+		FCMProjectID:       getEnv("FCM_PROJECT_ID", ""),
+		FCMCredentialsFile: getEnv("FCM_CREDENTIALS_FILE", ""),
+		APNsKeyFile:        getEnv("APNS_KEY_FILE", ""),
+		APNsKeyID:          getEnv("APNS_KEY_ID", ""),
+		APNsTeamID:         getEnv("APNS_TEAM_ID", ""),
+		APNsTopic:          getEnv("APNS_TOPIC", ""),
+		APNsProduction:     getEnv("APNS_PRODUCTION", "false") == "true",
+
+		StorageDriver:   getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalDir: getEnv("STORAGE_LOCAL_DIR", "./data/files"),
+		S3Endpoint:      getEnv("S3_ENDPOINT", "http://localhost:9000"),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        getEnv("S3_BUCKET", "messenger"),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3UsePathStyle:  getEnv("S3_USE_PATH_STYLE", "true") == "true",
+
+		SignedURLTTLSeconds: getEnvInt("SIGNED_URL_TTL_SECONDS", 300),
+
+		MaxImageUploadMB: getEnvInt("MAX_IMAGE_UPLOAD_MB", 20),
+		MaxVideoUploadMB: getEnvInt("MAX_VIDEO_UPLOAD_MB", 300),
+		MaxAudioUploadMB: getEnvInt("MAX_AUDIO_UPLOAD_MB", 50),
+		MaxFileUploadMB:  getEnvInt("MAX_FILE_UPLOAD_MB", 100),
+
+		UploadTempDir:     getEnv("UPLOAD_TEMP_DIR", "./data/uploads"),
+		UploadExpiryHours: getEnvInt("UPLOAD_EXPIRY_HOURS", 24),
+
+		MaxPendingUploads:  getEnvInt("MAX_PENDING_UPLOADS", 5),
+		MaxPendingUploadMB: getEnvInt("MAX_PENDING_UPLOAD_MB", 1024),
+
+		ScanMode:                getEnv("SCAN_MODE", "none"),
+		ClamdAddress:            getEnv("CLAMD_ADDRESS", "tcp://localhost:3310"),
+		ScanTimeoutSeconds:      getEnvInt("SCAN_TIMEOUT_SECONDS", 120),
+		QuarantineRetentionDays: getEnvInt("QUARANTINE_RETENTION_DAYS", 30),
+
+		MaxSyncedContacts:     getEnvInt("MAX_SYNCED_CONTACTS", 5000),
+		ContactSyncDailyLimit: getEnvInt("CONTACT_SYNC_DAILY_LIMIT", 2000),
+
+		UsernameChangeCooldownDays: getEnvInt("USERNAME_CHANGE_COOLDOWN_DAYS", 30),
+		UsernameReservationDays:    getEnvInt("USERNAME_RESERVATION_DAYS", 14),
+
+		AccountDeletionGraceDays: getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 30),
 	}
 
 	return connectDB()
@@ -67,3 +160,12 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
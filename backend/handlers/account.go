@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"messenger/config"
+	"messenger/media"
+	"messenger/middleware"
+	"messenger/models"
+	"messenger/services"
+	"messenger/storage"
+	"messenger/websocket"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 다른 사용자가 쓰고 있거나, 다른 사용자가 최근에 바꾸면서 예약된 아이디인지 확인한다. $2는 요청한 사용자 ID다.
+const usernameTakenQuery = `
+	SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 AND id != $2)
+		OR EXISTS(SELECT 1 FROM username_reservations WHERE username = $1 AND user_id != $2 AND reserved_until > NOW())
+`
+
+func ChangeUsername(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.ChangeUsernameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var oldUsername string
+	var tokenVersion int
+	var nextChangeAt sql.NullTime
+	var inCooldown bool
+	err = tx.QueryRow(`
+		SELECT username, token_version, username_changed_at + make_interval(days => $2),
+			COALESCE(username_changed_at + make_interval(days => $2) > NOW(), FALSE)
+		FROM users WHERE id = $1
+		FOR UPDATE
+	`, userID, config.AppConfig.UsernameChangeCooldownDays).Scan(&oldUsername, &tokenVersion, &nextChangeAt, &inCooldown)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if req.Username == oldUsername {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username unchanged"})
+		return
+	}
+	if inCooldown {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Username was changed recently", "next_change_at": nextChangeAt.Time})
+		return
+	}
+
+	var taken bool
+	if err := tx.QueryRow(usernameTakenQuery, req.Username, userID).Scan(&taken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET username = $1, username_changed_at = NOW(), updated_at = NOW() WHERE id = $2
+	`, req.Username, userID)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	// 이전 아이디는 일정 기간 예약해 두어 다른 사람이 바로 가져가지 못하게 한다 (본인은 되돌릴 수 있다)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM username_reservations WHERE username = $1", req.Username)
+	}
+	if err == nil {
+		_, err = tx.Exec(`
+			INSERT INTO username_reservations (username, user_id, reserved_until)
+			VALUES ($1, $2, NOW() + make_interval(days => $3))
+			ON CONFLICT (username) DO UPDATE SET user_id = EXCLUDED.user_id, reserved_until = EXCLUDED.reserved_until
+		`, oldUsername, userID, config.AppConfig.UsernameReservationDays)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change username"})
+		return
+	}
+
+	notifyProfileUpdated(userID, "username")
+
+	// 토큰에 아이디가 들어 있으므로 새로 발급한다
+	token, err := issueToken(userID, req.Username, tokenVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Username changed successfully",
+		"username": req.Username,
+		"token":    token,
+	})
+}
+
+// SendPhoneChangeCode 는 새 번호로 인증 코드를 보낸다. 번호는 VerifyPhoneChange 에서 코드를 확인한 뒤에 바뀐다.
+func SendPhoneChangeCode(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.SendCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, message := checkNewPhone(userID, req.Phone); status != 0 {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	if !reserveSMSSend(c, req.Phone) {
+		return
+	}
+
+	code := services.GenerateVerificationCode()
+
+	if err := services.SaveVerificationCode(req.Phone, code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save verification code"})
+		return
+	}
+
+	if err := services.SendSMS(req.Phone, code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send SMS"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent"})
+}
+
+// VerifyPhoneChange 는 새 번호의 인증 코드와 현재 비밀번호를 확인한 뒤 번호를 바꾼다.
+// 번호로 비밀번호를 재설정할 수 있으므로 토큰만으로는 바꿀 수 없게 한다.
+func VerifyPhoneChange(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.ChangePhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var passwordHash string
+	if err := config.DB.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&passwordHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if !services.CheckPassword(req.CurrentPassword, passwordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if status, message := checkNewPhone(userID, req.Phone); status != 0 {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	if !verifyCodeAttempt(c, req.Phone, req.Code) {
+		return
+	}
+
+	// 같은 코드로 다시 바꾸지 못하게 한다
+	if err := services.ConsumeVerificationCode(req.Phone, req.Code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var oldPhone string
+	if err := tx.QueryRow("SELECT phone FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&oldPhone); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET phone = $1, phone_hash = $2, updated_at = NOW() WHERE id = $3
+	`, req.Phone, services.PhoneHash(req.Phone), userID)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Phone number already registered"})
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change phone number"})
+		return
+	}
+
+	// 본인이 바꾼 것이 아니라면 알아챌 수 있도록 이전 번호로 안내한다
+	notice := "계정의 전화번호가 " + time.Now().Format("2006-01-02 15:04") + "에 변경되었습니다. 본인이 변경하지 않았다면 고객센터로 문의해 주세요."
+	if err := services.SendNotice(oldPhone, notice); err != nil {
+		log.Printf("Failed to notify old phone for user %d: %v", userID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Phone number changed successfully", "phone": req.Phone})
+}
+
+// checkNewPhone 은 바꿀 번호가 유효한지 확인한다. 문제가 없으면 status 0.
+func checkNewPhone(userID int, phone string) (int, string) {
+	if services.NormalizePhone(phone) == "" {
+		return http.StatusBadRequest, "Invalid phone number"
+	}
+
+	var current string
+	config.DB.QueryRow("SELECT phone FROM users WHERE id = $1", userID).Scan(&current)
+	if services.PhoneHash(current) == services.PhoneHash(phone) {
+		return http.StatusBadRequest, "Phone number unchanged"
+	}
+
+	var exists bool
+	err := config.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM users WHERE (phone = $1 OR phone_hash = $2) AND id != $3)
+	`, phone, services.PhoneHash(phone), userID).Scan(&exists)
+	if err != nil {
+		return http.StatusInternalServerError, "Database error"
+	}
+	if exists {
+		return http.StatusConflict, "Phone number already registered"
+	}
+	return 0, ""
+}
+
+// DeleteAccount 는 탈퇴를 요청한다. 유예 기간 동안은 다른 사용자에게 보이지 않고,
+// 그 안에 다시 로그인하면 복구된다. 기간이 지나면 StartAccountPurge 가 계정을 지운다.
+func DeleteAccount(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var passwordHash string
+	if err := config.DB.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&passwordHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if !services.CheckPassword(req.Password, passwordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// 기존 세션은 모두 끊는다. 복구하려면 다시 로그인해야 한다
+	var purgeAt time.Time
+	err = tx.QueryRow(`
+		UPDATE users SET deleted_at = NOW(), token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1
+		RETURNING deleted_at + make_interval(days => $2)
+	`, userID, config.AppConfig.AccountDeletionGraceDays).Scan(&purgeAt)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM push_tokens WHERE user_id = $1", userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	websocket.DisconnectUser(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account scheduled for deletion", "purge_at": purgeAt})
+}
+
+// StartAccountPurge 는 유예 기간이 지난 탈퇴 계정을 주기적으로 지운다.
+func StartAccountPurge() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			purgeDeletedAccounts()
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeDeletedAccounts() {
+	rows, err := config.DB.Query(`
+		SELECT id FROM users
+		WHERE deleted_at IS NOT NULL AND deleted_at < NOW() - make_interval(days => $1)
+	`, config.AppConfig.AccountDeletionGraceDays)
+	if err != nil {
+		log.Printf("Failed to query deleted accounts: %v", err)
+		return
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := purgeAccount(id); err != nil {
+			log.Printf("Failed to purge account %d: %v", id, err)
+		}
+	}
+}
+
+// purgeAccount 는 계정과 개인 데이터를 지운다. 보낸 메시지는 상대방 대화 기록으로 남기되
+// sender_id 가 NULL 이 되어 익명으로 보이고, 고정·공지 작성자도 NULL 이 된다.
+// 친구, 차단, 연락처, 설정 등 나머지 사용자 데이터는 ON DELETE CASCADE 로 함께 지워진다.
+func purgeAccount(userID int) error {
+	var phone string
+	var legacyKeys []string
+	var profileKey, profileSHA, backgroundKey, backgroundSHA sql.NullString
+	err := config.DB.QueryRow(`
+		SELECT phone, profile_image_key, profile_image_sha256, background_image_key, background_image_sha256
+		FROM users WHERE id = $1 AND deleted_at IS NOT NULL
+	`, userID).Scan(&phone, &profileKey, &profileSHA, &backgroundKey, &backgroundSHA)
+	if err != nil {
+		return err
+	}
+	// blob으로 저장된 이미지는 트리거가 참조 수를 줄이고, 그 이전 이미지는 직접 지운다
+	if profileKey.Valid && !profileSHA.Valid {
+		legacyKeys = append(legacyKeys, profileKey.String)
+	}
+	if backgroundKey.Valid && !backgroundSHA.Valid {
+		legacyKeys = append(legacyKeys, backgroundKey.String)
+	}
+
+	// 방장 넘기기와 빈 방 정리는 직접 나갈 때와 같게 처리한다
+	rows, err := config.DB.Query(`
+		SELECT room_id FROM chat_room_members WHERE user_id = $1 AND left_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+	var roomIDs []int
+	for rows.Next() {
+		var roomID int
+		if rows.Scan(&roomID) == nil {
+			roomIDs = append(roomIDs, roomID)
+		}
+	}
+	rows.Close()
+
+	for _, roomID := range roomIDs {
+		if err := leaveRoom(roomID, userID); err != nil {
+			return err
+		}
+	}
+
+	// 완료되지 않은 업로드의 임시 파일은 행과 함께 지워야 남지 않는다
+	rows, err = config.DB.Query("SELECT id FROM uploads WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	var uploadIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			uploadIDs = append(uploadIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range uploadIDs {
+		removeUpload(id)
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// phone_verifications 는 users 를 참조하지 않으므로 번호로 지운다
+	_, err = tx.Exec("DELETE FROM phone_verifications WHERE phone = $1", phone)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM users WHERE id = $1 AND deleted_at IS NOT NULL", userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, key := range legacyKeys {
+		media.DeleteWithThumbnails(context.Background(), storage.Default, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"messenger/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetStorageReport(c *gin.Context) {
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	report, err := services.GetStorageReport(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func GetQuarantinedFiles(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+
+	blobs, err := services.GetQuarantinedBlobs(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get quarantined files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, blobs)
+}
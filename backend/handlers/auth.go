@@ -20,6 +20,10 @@ func SendCode(c *gin.Context) {
 		return
 	}
 
+	if !reserveSMSSend(c, req.Phone) {
+		return
+	}
+
 	code := services.GenerateVerificationCode()
 
 	if err := services.SaveVerificationCode(req.Phone, code); err != nil {
@@ -42,15 +46,41 @@ func VerifyCode(c *gin.Context) {
 		return
 	}
 
-	verified, err := services.VerifyCode(req.Phone, req.Code)
-	if err != nil || !verified {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification code"})
+	if !verifyCodeAttempt(c, req.Phone, req.Code) {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Phone verified successfully"})
 }
 
+// reserveSMSSend 는 번호와 IP별 발송 한도를 확인한다. 보낼 수 없으면 응답을 쓰고 false를 돌려준다.
+func reserveSMSSend(c *gin.Context, phone string) bool {
+	err := services.ReserveSMSSend(phone, c.ClientIP())
+	if err == services.ErrTooManyAttempts {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many verification codes requested, try again later"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	return true
+}
+
+// verifyCodeAttempt 는 실패 횟수 제한을 적용해 인증 코드를 확인한다. 실패하면 응답을 쓰고 false를 돌려준다.
+func verifyCodeAttempt(c *gin.Context, phone, code string) bool {
+	verified, err := services.VerifyCodeAttempt(phone, code, c.ClientIP())
+	if err == services.ErrTooManyAttempts {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+		return false
+	}
+	if err != nil || !verified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification code"})
+		return false
+	}
+	return true
+}
+
 func Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -64,7 +94,7 @@ func Register(c *gin.Context) {
 	}
 
 	var exists bool
-	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", req.Username).Scan(&exists)
+	err := config.DB.QueryRow(usernameTakenQuery, req.Username, 0).Scan(&exists)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -74,7 +104,8 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE phone = $1)", req.Phone).Scan(&exists)
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE phone = $1 OR phone_hash = $2)",
+		req.Phone, services.PhoneHash(req.Phone)).Scan(&exists)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -92,10 +123,10 @@ func Register(c *gin.Context) {
 
 	var userID int
 	err = config.DB.QueryRow(`
-		INSERT INTO users (username, phone, password_hash, name)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO users (username, phone, password_hash, name, phone_hash)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
-	`, req.Username, req.Phone, hashedPassword, req.Name).Scan(&userID)
+	`, req.Username, req.Phone, hashedPassword, req.Name, services.PhoneHash(req.Phone)).Scan(&userID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
@@ -113,12 +144,14 @@ func Login(c *gin.Context) {
 	}
 
 	var user models.User
+	var tokenVersion int
+	var deletedAt sql.NullTime
 	err := config.DB.QueryRow(`
-		SELECT id, username, phone, password_hash, name, created_at, updated_at
+		SELECT id, username, phone, password_hash, name, token_version, deleted_at, created_at, updated_at
 		FROM users WHERE username = $1
 	`, req.Username).Scan(
 		&user.ID, &user.Username, &user.Phone, &user.PasswordHash,
-		&user.Name, &user.CreatedAt, &user.UpdatedAt,
+		&user.Name, &tokenVersion, &deletedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -135,24 +168,39 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	claims := &middleware.Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	// 탈퇴 유예 기간 안에 로그인하면 탈퇴 요청을 취소한다
+	if deletedAt.Valid {
+		_, err = config.DB.Exec("UPDATE users SET deleted_at = NULL, updated_at = NOW() WHERE id = $1", user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore account"})
+			return
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.AppConfig.JWTSecret))
+	tokenString, err := issueToken(user.ID, user.Username, tokenVersion)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: tokenString,
-		User:  user,
+		Token:    tokenString,
+		User:     user,
+		Restored: deletedAt.Valid,
 	})
 }
+
+func issueToken(userID int, username string, tokenVersion int) (string, error) {
+	claims := &middleware.Claims{
+		UserID:       userID,
+		Username:     username,
+		TokenVersion: tokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.JWTSecret))
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"database/sql"
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetBlockedUsers(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	rows, err := config.DB.Query(`
+		SELECT u.id, u.username, u.name, ub.created_at
+		FROM user_blocks ub
+		JOIN users u ON u.id = ub.blocked_id
+		WHERE ub.blocker_id = $1
+		ORDER BY ub.created_at DESC
+	`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get blocked users"})
+		return
+	}
+	defer rows.Close()
+
+	blocked := []models.BlockedUser{}
+	for rows.Next() {
+		var b models.BlockedUser
+		if err := rows.Scan(&b.UserID, &b.Username, &b.Name, &b.BlockedAt); err == nil {
+			blocked = append(blocked, b)
+		}
+	}
+
+	c.JSON(http.StatusOK, blocked)
+}
+
+func BlockUser(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.BlockUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.UserID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
+		return
+	}
+
+	var exists bool
+	config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", req.UserID).Scan(&exists)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, userID, req.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	// 차단하면 친구 관계와 대기 중인 친구 요청도 정리한다
+	_, err = tx.Exec(`
+		DELETE FROM friends
+		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
+	`, userID, req.UserID)
+	if err == nil {
+		_, err = tx.Exec(`
+			UPDATE friend_requests SET status = 'canceled', responded_at = NOW()
+			WHERE status = 'pending'
+			AND ((requester_id = $1 AND recipient_id = $2) OR (requester_id = $2 AND recipient_id = $1))
+		`, userID, req.UserID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+func UnblockUser(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	blockedID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var deleted int
+	err = config.DB.QueryRow(`
+		DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2
+		RETURNING blocked_id
+	`, userID, blockedID).Scan(&deleted)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
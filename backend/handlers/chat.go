@@ -5,10 +5,12 @@ import (
 	"messenger/config"
 	"messenger/middleware"
 	"messenger/models"
+	"messenger/services"
 	"messenger/websocket"
 	"net/http"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,13 +18,28 @@ import (
 func GetRooms(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
+	archived := c.Query("archived") == "true"
+
+	var folderID sql.NullInt64
+	if f := c.Query("folder"); f != "" {
+		parsed, err := strconv.Atoi(f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return
+		}
+		folderID = sql.NullInt64{Int64: int64(parsed), Valid: true}
+	}
+
 	rows, err := config.DB.Query(`
 		SELECT DISTINCT cr.id, cr.type, cr.name, cr.created_at,
-			(SELECT COUNT(*) FROM chat_room_members WHERE room_id = cr.id AND left_at IS NULL) as member_count
+			(SELECT COUNT(*) FROM chat_room_members WHERE room_id = cr.id AND left_at IS NULL) as member_count,
+			crm.pinned_at IS NOT NULL, crm.archived_at IS NOT NULL, crm.folder_id
 		FROM chat_rooms cr
 		JOIN chat_room_members crm ON cr.id = crm.room_id
 		WHERE crm.user_id = $1 AND crm.left_at IS NULL
-	`, userID)
+		AND (crm.archived_at IS NOT NULL) = $2
+		AND ($3::INTEGER IS NULL OR crm.folder_id = $3)
+	`, userID, archived, folderID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rooms"})
@@ -35,9 +52,24 @@ func GetRooms(c *gin.Context) {
 		var room models.ChatRoomListItem
 		var name sql.NullString
 		var createdAt sql.NullTime
-		if err := rows.Scan(&room.ID, &room.Type, &name, &createdAt, &room.MemberCount); err == nil {
+		var roomFolderID sql.NullInt64
+		if err := rows.Scan(&room.ID, &room.Type, &name, &createdAt, &room.MemberCount, &room.IsPinned, &room.IsArchived, &roomFolderID); err == nil {
 			if name.Valid {
 				room.Name = name.String
+			} else if room.Type == "direct" {
+				// 1:1 채팅방은 상대 이름(내가 지정한 별명 우선)으로 보여준다
+				config.DB.QueryRow(`
+					SELECT `+displayName("$2")+`
+					FROM chat_room_members crm
+					JOIN users u ON crm.user_id = u.id
+					WHERE crm.room_id = $1 AND crm.user_id != $2
+					ORDER BY crm.left_at IS NULL DESC
+					LIMIT 1
+				`, room.ID, userID).Scan(&room.Name)
+			}
+			if roomFolderID.Valid {
+				fid := int(roomFolderID.Int64)
+				room.FolderID = &fid
 			}
 			if createdAt.Valid {
 				room.LastTime = &createdAt.Time
@@ -48,23 +80,19 @@ func GetRooms(c *gin.Context) {
 			var lastMsgType sql.NullString
 			var lastMsgTime sql.NullTime
 			config.DB.QueryRow(`
-				SELECT m.content, u.name, m.type, m.created_at
+				SELECT m.content, `+displayName("$2")+`, m.type, m.created_at
 				FROM messages m
 				LEFT JOIN users u ON m.sender_id = u.id
 				WHERE m.room_id = $1
+				`+blockedSenderFilter("$2")+`
 				ORDER BY m.created_at DESC
 				LIMIT 1
-			`, room.ID).Scan(&lastMsg, &lastSender, &lastMsgType, &lastMsgTime)
+			`, room.ID, userID).Scan(&lastMsg, &lastSender, &lastMsgType, &lastMsgTime)
 
 			if lastMsg.Valid {
 				room.LastMessage = lastMsg.String
 			} else if lastMsgType.Valid {
-				switch lastMsgType.String {
-				case "image":
-					room.LastMessage = "사진을 보냈습니다"
-				case "video":
-					room.LastMessage = "동영상을 보냈습니다"
-				}
+				room.LastMessage = messagePreview(lastMsgType.String, "")
 			}
 			if lastSender.Valid {
 				room.LastSender = lastSender.String
@@ -73,11 +101,13 @@ func GetRooms(c *gin.Context) {
 				room.LastTime = &lastMsgTime.Time
 			}
 
-			var isMuted bool
-			config.DB.QueryRow(`
-				SELECT EXISTS(SELECT 1 FROM notification_mutes WHERE user_id = $1 AND room_id = $2)
-			`, userID, room.ID).Scan(&isMuted)
-			room.IsMuted = isMuted
+			notification := services.GetRoomNotificationSetting(userID, room.ID)
+			room.IsMuted = notification.Level == services.NotifyMuted
+			room.NotificationLevel = notification.Level
+			room.MutedUntil = notification.MutedUntil
+
+			room.PinnedMessages = loadPinnedMessages(room.ID)
+			room.Announcement = loadAnnouncement(room.ID, userID)
 
 			rooms = append(rooms, room)
 		}
@@ -88,6 +118,9 @@ func GetRooms(c *gin.Context) {
 	}
 
 	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].IsPinned != rooms[j].IsPinned {
+			return rooms[i].IsPinned
+		}
 		if rooms[i].LastTime == nil {
 			return false
 		}
@@ -100,6 +133,65 @@ func GetRooms(c *gin.Context) {
 	c.JSON(http.StatusOK, rooms)
 }
 
+func GetRoom(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	_, role, err := getMembership(roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+
+	room := models.RoomDetail{MyRole: role}
+	var name sql.NullString
+	err = config.DB.QueryRow(`
+		SELECT id, type, name, created_at FROM chat_rooms WHERE id = $1
+	`, roomID).Scan(&room.ID, &room.Type, &name, &room.CreatedAt)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	if name.Valid {
+		room.Name = name.String
+	}
+
+	room.Members = []models.RoomMember{}
+	rows, err := config.DB.Query(`
+		SELECT crm.id, crm.room_id, crm.user_id, u.username, `+displayName("$2")+`, crm.joined_at,
+			CASE WHEN crm.user_id = $2 OR `+services.ReadReceiptsCondition("crm.user_id")+`
+				THEN COALESCE(crm.last_read_message_id, 0) ELSE 0 END, crm.role
+		FROM chat_room_members crm
+		JOIN users u ON crm.user_id = u.id
+		WHERE crm.room_id = $1 AND crm.left_at IS NULL
+	`, roomID, userID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var m models.RoomMember
+			if err := rows.Scan(&m.ID, &m.RoomID, &m.UserID, &m.Username, &m.Name, &m.JoinedAt, &m.LastReadMessageID, &m.Role); err == nil {
+				room.Members = append(room.Members, m)
+			}
+		}
+	}
+
+	notification := services.GetRoomNotificationSetting(userID, roomID)
+	room.IsMuted = notification.Level == services.NotifyMuted
+	room.NotificationLevel = notification.Level
+	room.MutedUntil = notification.MutedUntil
+
+	room.PinnedMessages = loadPinnedMessages(roomID)
+	room.Announcement = loadAnnouncement(roomID, userID)
+
+	c.JSON(http.StatusOK, room)
+}
+
 func CreateRoom(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
@@ -125,6 +217,25 @@ func CreateRoom(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"room_id": existingRoomID, "existing": true})
 			return
 		}
+
+		// 새 1:1 채팅은 차단 관계와 상대의 메시지 허용 범위를 함께 확인한다
+		if !services.CanMessage(userID, req.MemberIDs[0]) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot start a chat with this user"})
+			return
+		}
+	}
+
+	if req.Type == "group" {
+		var rejected []int
+		for _, memberID := range req.MemberIDs {
+			if memberID != userID && !services.CanAddToGroup(userID, memberID) {
+				rejected = append(rejected, memberID)
+			}
+		}
+		if len(rejected) > 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Some users cannot be added to groups", "user_ids": rejected})
+			return
+		}
 	}
 
 	tx, err := config.DB.Begin()
@@ -145,10 +256,16 @@ func CreateRoom(c *gin.Context) {
 	}
 
 	for _, memberID := range allMembers {
+		role := "member"
+		if req.Type == "group" && memberID == userID {
+			role = "owner"
+		}
 		_, err = tx.Exec(`
-			INSERT INTO chat_room_members (room_id, user_id) VALUES ($1, $2)
-			ON CONFLICT (room_id, user_id) DO UPDATE SET left_at = NULL
-		`, roomID, memberID)
+			INSERT INTO chat_room_members (room_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (room_id, user_id) DO UPDATE SET
+				joined_at = CASE WHEN chat_room_members.left_at IS NULL THEN chat_room_members.joined_at ELSE NOW() END,
+				left_at = NULL, role = EXCLUDED.role
+		`, roomID, memberID, role)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add members"})
 			return
@@ -173,14 +290,38 @@ func LeaveRoom(c *gin.Context) {
 		return
 	}
 
-	_, err = config.DB.Exec(`
+	if err := leaveRoom(roomID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave room"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left room successfully"})
+}
+
+// leaveRoom 은 userID 를 방에서 내보내고, 방장이었으면 방장을 넘기고, 남은 멤버가 없으면 방을 지운다.
+func leaveRoom(roomID, userID int) error {
+	var leavingRole string
+	err := config.DB.QueryRow(`
 		UPDATE chat_room_members SET left_at = NOW()
 		WHERE room_id = $1 AND user_id = $2 AND left_at IS NULL
-	`, roomID, userID)
+		RETURNING role
+	`, roomID, userID).Scan(&leavingRole)
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave room"})
-		return
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	// 방장이 나가면 가장 먼저 들어온 멤버에게 방장을 넘긴다
+	if leavingRole == "owner" {
+		config.DB.Exec(`
+			UPDATE chat_room_members SET role = 'owner'
+			WHERE id = (
+				SELECT id FROM chat_room_members
+				WHERE room_id = $1 AND left_at IS NULL
+				ORDER BY (role = 'admin') DESC, joined_at
+				LIMIT 1
+			)
+		`, roomID)
 	}
 
 	var activeMembers int
@@ -195,10 +336,11 @@ func LeaveRoom(c *gin.Context) {
 		config.DB.Exec("DELETE FROM chat_rooms WHERE id = $1", roomID)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Left room successfully"})
+	return nil
 }
 
 func GetRoomMembers(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	roomIDStr := c.Param("id")
 
 	roomID, err := strconv.Atoi(roomIDStr)
@@ -208,11 +350,13 @@ func GetRoomMembers(c *gin.Context) {
 	}
 
 	rows, err := config.DB.Query(`
-		SELECT crm.id, crm.room_id, crm.user_id, u.username, u.name, crm.joined_at, COALESCE(crm.last_read_message_id, 0)
+		SELECT crm.id, crm.room_id, crm.user_id, u.username, `+displayName("$2")+`, crm.joined_at,
+			CASE WHEN crm.user_id = $2 OR `+services.ReadReceiptsCondition("crm.user_id")+`
+				THEN COALESCE(crm.last_read_message_id, 0) ELSE 0 END, crm.role
 		FROM chat_room_members crm
 		JOIN users u ON crm.user_id = u.id
 		WHERE crm.room_id = $1 AND crm.left_at IS NULL
-	`, roomID)
+	`, roomID, userID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get members"})
@@ -223,7 +367,7 @@ func GetRoomMembers(c *gin.Context) {
 	var members []models.RoomMember
 	for rows.Next() {
 		var m models.RoomMember
-		if err := rows.Scan(&m.ID, &m.RoomID, &m.UserID, &m.Username, &m.Name, &m.JoinedAt, &m.LastReadMessageID); err == nil {
+		if err := rows.Scan(&m.ID, &m.RoomID, &m.UserID, &m.Username, &m.Name, &m.JoinedAt, &m.LastReadMessageID, &m.Role); err == nil {
 			members = append(members, m)
 		}
 	}
@@ -235,43 +379,209 @@ func GetRoomMembers(c *gin.Context) {
 	c.JSON(http.StatusOK, members)
 }
 
-func ToggleMute(c *gin.Context) {
+func UpdateMemberRole(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	roomIDStr := c.Param("id")
 
-	roomID, err := strconv.Atoi(roomIDStr)
+	roomID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
 		return
 	}
 
-	var isMuted bool
+	targetID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roomType, role, err := getMembership(roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+	if roomType != "group" || role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the room owner can change roles"})
+		return
+	}
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change your own role"})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		UPDATE chat_room_members SET role = $1
+		WHERE room_id = $2 AND user_id = $3 AND left_at IS NULL
+	`, req.Role, roomID, targetID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+		Type: "member_role_updated",
+		Payload: gin.H{
+			"room_id": roomID,
+			"user_id": targetID,
+			"role":    req.Role,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"user_id": targetID, "role": req.Role})
+}
+
+func UpdateRoomPreferences(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	var req models.UpdateRoomPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, _, err := getMembership(roomID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+
+	var folderID sql.NullInt64
+	if req.FolderID != nil && *req.FolderID != 0 {
+		var owned bool
+		config.DB.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM chat_folders WHERE id = $1 AND user_id = $2)
+		`, *req.FolderID, userID).Scan(&owned)
+		if !owned {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+		folderID = sql.NullInt64{Int64: int64(*req.FolderID), Valid: true}
+	}
+
+	// nil인 항목은 기존 값을 유지한다
+	var isPinned, isArchived bool
+	var roomFolderID sql.NullInt64
 	err = config.DB.QueryRow(`
-		SELECT EXISTS(SELECT 1 FROM notification_mutes WHERE user_id = $1 AND room_id = $2)
-	`, userID, roomID).Scan(&isMuted)
+		UPDATE chat_room_members SET
+			pinned_at = CASE
+				WHEN $1::BOOLEAN IS NULL THEN pinned_at
+				WHEN $1 THEN COALESCE(pinned_at, NOW())
+				ELSE NULL END,
+			archived_at = CASE
+				WHEN $2::BOOLEAN IS NULL THEN archived_at
+				WHEN $2 THEN COALESCE(archived_at, NOW())
+				ELSE NULL END,
+			folder_id = CASE WHEN $3 THEN $4 ELSE folder_id END
+		WHERE room_id = $5 AND user_id = $6 AND left_at IS NULL
+		RETURNING pinned_at IS NOT NULL, archived_at IS NOT NULL, folder_id
+	`, req.IsPinned, req.IsArchived, req.FolderID != nil, folderID, roomID, userID).Scan(&isPinned, &isArchived, &roomFolderID)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room preferences"})
 		return
 	}
 
-	if isMuted {
-		_, err = config.DB.Exec(`
-			DELETE FROM notification_mutes WHERE user_id = $1 AND room_id = $2
-		`, userID, roomID)
-	} else {
-		_, err = config.DB.Exec(`
-			INSERT INTO notification_mutes (user_id, room_id) VALUES ($1, $2)
-			ON CONFLICT (user_id, room_id) DO NOTHING
-		`, userID, roomID)
+	response := gin.H{
+		"room_id":     roomID,
+		"is_pinned":   isPinned,
+		"is_archived": isArchived,
+		"folder_id":   nil,
 	}
+	if roomFolderID.Valid {
+		response["folder_id"] = roomFolderID.Int64
+	}
+
+	// 다른 기기와 동기화
+	websocket.BroadcastToUser(userID, models.WebSocketMessage{
+		Type:    "room_preferences_updated",
+		Payload: response,
+	})
 
+	c.JSON(http.StatusOK, response)
+}
+
+func ToggleMute(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	roomIDStr := c.Param("id")
+
+	roomID, err := strconv.Atoi(roomIDStr)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if _, _, err := getMembership(roomID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+
+	current := services.GetRoomNotificationSetting(userID, roomID)
+
+	// 본문이 없으면 기존처럼 무기한 음소거를 켜고 끈다
+	req := models.MuteRequest{Level: services.NotifyMuted, Duration: "forever"}
+	if current.Level != services.NotifyAll {
+		req.Level = services.NotifyAll
+	}
+	if c.Request.ContentLength > 0 {
+		req = models.MuteRequest{}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Level == "" {
+			req.Level = services.NotifyMuted
+		}
+	}
+
+	var mutedUntil *time.Time
+	if req.Level != services.NotifyAll {
+		switch {
+		case req.Until != nil:
+			if !req.Until.After(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "until must be in the future"})
+				return
+			}
+			until := req.Until.Local()
+			mutedUntil = &until
+		case req.Duration == "" || req.Duration == "forever":
+		default:
+			d, ok := models.MuteDurations[req.Duration]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mute duration"})
+				return
+			}
+			until := time.Now().Add(d)
+			mutedUntil = &until
+		}
+	}
+
+	if err := services.SetRoomNotificationSetting(userID, roomID, req.Level, mutedUntil); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update mute setting"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"is_muted": !isMuted})
+	c.JSON(http.StatusOK, gin.H{
+		"is_muted":           req.Level == services.NotifyMuted,
+		"notification_level": req.Level,
+		"muted_until":        mutedUntil,
+	})
 }
 
 func MarkRead(c *gin.Context) {
@@ -303,14 +613,37 @@ func MarkRead(c *gin.Context) {
 		return
 	}
 
-	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+	readMessage := models.WebSocketMessage{
 		Type: "messages_read",
 		Payload: gin.H{
 			"room_id":              roomID,
 			"user_id":              userID,
 			"last_read_message_id": req.MessageID,
 		},
-	})
+	}
+
+	// 읽음 표시를 끈 사용자는 읽은 위치를 내 다른 기기에만 동기화한다
+	if services.SendsReadReceipts(userID) {
+		websocket.BroadcastToRoom(roomID, readMessage)
+	} else {
+		websocket.BroadcastToUser(userID, readMessage)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
+
+func getMembership(roomID, userID int) (string, string, error) {
+	var roomType, role string
+	err := config.DB.QueryRow(`
+		SELECT cr.type, crm.role
+		FROM chat_room_members crm
+		JOIN chat_rooms cr ON crm.room_id = cr.id
+		WHERE crm.room_id = $1 AND crm.user_id = $2 AND crm.left_at IS NULL
+	`, roomID, userID).Scan(&roomType, &role)
+	return roomType, role, err
+}
+
+// 1:1 채팅방은 두 멤버 모두 관리 권한을 가진다
+func canManageRoom(roomType, role string) bool {
+	return roomType == "direct" || role == "owner" || role == "admin"
+}
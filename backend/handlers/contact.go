@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"messenger/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// SyncContacts 는 주소록의 전화번호 해시(services.PhoneHash 규칙)를 받아 가입한 사용자를 찾아준다.
+// 원래 번호는 서버로 보내지 않는다.
+func SyncContacts(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.ContactSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	maxContacts := config.AppConfig.MaxSyncedContacts
+	if len(req.Added) > maxContacts || len(req.Removed) > maxContacts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many contacts", "max_contacts": maxContacts})
+		return
+	}
+
+	added, ok := normalizePhoneHashes(req.Added)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone hash"})
+		return
+	}
+	removed, ok := normalizePhoneHashes(req.Removed)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone hash"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// 한도 사용량은 동기화와 함께 커밋되므로 아래에서 실패하면 되돌려진다
+	var newCount int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM unnest($2::TEXT[]) h
+		WHERE NOT EXISTS (SELECT 1 FROM user_contacts WHERE user_id = $1 AND phone_hash = h)
+	`, userID, pq.Array(added)).Scan(&newCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := services.ReserveContactSyncQuota(tx, userID, newCount); err == services.ErrContactSyncLimit {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Contact sync limit exceeded, try again tomorrow"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if req.Full {
+		_, err = tx.Exec(`
+			DELETE FROM user_contacts WHERE user_id = $1 AND NOT (phone_hash = ANY($2::TEXT[]))
+		`, userID, pq.Array(added))
+	} else {
+		_, err = tx.Exec(`
+			DELETE FROM user_contacts WHERE user_id = $1 AND phone_hash = ANY($2::TEXT[])
+		`, userID, pq.Array(removed))
+	}
+	if err == nil {
+		_, err = tx.Exec(`
+			INSERT INTO user_contacts (user_id, phone_hash)
+			SELECT $1, h FROM unnest($2::TEXT[]) h
+			ON CONFLICT (user_id, phone_hash) DO NOTHING
+		`, userID, pq.Array(added))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync contacts"})
+		return
+	}
+
+	var contactCount int
+	tx.QueryRow("SELECT COUNT(*) FROM user_contacts WHERE user_id = $1", userID).Scan(&contactCount)
+	if contactCount > maxContacts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many contacts", "max_contacts": maxContacts})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync contacts"})
+		return
+	}
+
+	// 변경분 동기화에서는 새로 추가한 번호의 매칭만 돌려준다
+	matches, err := loadContactMatches(userID, added)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to match contacts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ContactSyncResponse{Matches: matches, ContactCount: contactCount})
+}
+
+func GetContactMatches(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	matches, err := loadContactMatches(userID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to match contacts"})
+		return
+	}
+
+	var contactCount int
+	config.DB.QueryRow("SELECT COUNT(*) FROM user_contacts WHERE user_id = $1", userID).Scan(&contactCount)
+
+	c.JSON(http.StatusOK, models.ContactSyncResponse{Matches: matches, ContactCount: contactCount})
+}
+
+// loadContactMatches 는 내 연락처 중 가입했고 번호 검색을 허용한 사용자를 찾는다. hashes 가 nil 이면 전체 연락처.
+func loadContactMatches(userID int, hashes []string) ([]models.ContactMatch, error) {
+	rows, err := config.DB.Query(`
+		SELECT u.id, u.username, u.name, u.phone_hash,
+			EXISTS(SELECT 1 FROM friends f WHERE f.user_id = $1 AND f.friend_id = u.id)
+		FROM user_contacts uc
+		JOIN users u ON u.phone_hash = uc.phone_hash
+		WHERE uc.user_id = $1 AND u.id != $1
+		AND ($2::TEXT[] IS NULL OR uc.phone_hash = ANY($2::TEXT[]))
+		AND `+services.DiscoverableByPhoneCondition("u")+`
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks ub
+			WHERE (ub.blocker_id = $1 AND ub.blocked_id = u.id) OR (ub.blocker_id = u.id AND ub.blocked_id = $1)
+		)
+		ORDER BY u.name
+	`, userID, pq.Array(hashes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []models.ContactMatch{}
+	for rows.Next() {
+		var m models.ContactMatch
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Name, &m.PhoneHash, &m.IsFriend); err == nil {
+			matches = append(matches, m)
+		}
+	}
+	return matches, rows.Err()
+}
+
+// normalizePhoneHashes 는 해시를 소문자로 맞추고 중복을 없앤다. 형식이 틀린 값이 있으면 false.
+func normalizePhoneHashes(hashes []string) ([]string, bool) {
+	seen := make(map[string]bool, len(hashes))
+	result := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !services.ValidPhoneHash(h) {
+			return nil, false
+		}
+		if !seen[h] {
+			seen[h] = true
+			result = append(result, h)
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/services"
+	"messenger/storage"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportUserData 는 내 개인 데이터를 zip으로 내려준다 (프로필, 설정, 친구, 차단, 연락처, 채팅방, 내가 보낸 메시지와 파일).
+// 파일이 클 수 있으므로 메모리에 모으지 않고 응답에 바로 쓴다.
+func ExportUserData(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	profile, images, err := loadExportProfile(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-export-%s.zip", profile["username"], time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDisposition("attachment", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// 응답을 쓰기 시작한 뒤에는 상태 코드를 바꿀 수 없으므로 실패하면 로그만 남기고 중단한다
+	zw := zip.NewWriter(c.Writer)
+	if err := writeUserExport(c, zw, userID, profile, images); err != nil {
+		log.Printf("Failed to export data for user %d: %v", userID, err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Failed to export data for user %d: %v", userID, err)
+	}
+}
+
+// 내보낼 이미지. storageKey 가 없으면 data 를 그대로 쓴다 (스토리지로 옮기기 전 프로필 사진)
+type exportImage struct {
+	name       string
+	mimeType   string
+	storageKey string
+	data       []byte
+}
+
+func loadExportProfile(userID int) (gin.H, []exportImage, error) {
+	var username, phone, name string
+	var statusMessage, profileKey, profileMime, backgroundKey, backgroundMime sql.NullString
+	var profileImage []byte
+	var usernameChangedAt, lastSeenAt sql.NullTime
+	var createdAt, updatedAt time.Time
+
+	err := config.DB.QueryRow(`
+		SELECT username, phone, name, status_message, profile_image, profile_image_key, profile_image_mime,
+			background_image_key, background_image_mime, username_changed_at, last_seen_at, created_at, updated_at
+		FROM users WHERE id = $1
+	`, userID).Scan(&username, &phone, &name, &statusMessage, &profileImage, &profileKey, &profileMime,
+		&backgroundKey, &backgroundMime, &usernameChangedAt, &lastSeenAt, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	profile := gin.H{
+		"id":                  userID,
+		"username":            username,
+		"phone":               phone,
+		"name":                name,
+		"status_message":      statusMessage.String,
+		"username_changed_at": nullTime(usernameChangedAt),
+		"last_seen_at":        nullTime(lastSeenAt),
+		"created_at":          createdAt,
+		"updated_at":          updatedAt,
+	}
+
+	var images []exportImage
+	if profileKey.Valid || profileImage != nil {
+		images = append(images, exportImage{"profile/profile_image", profileMime.String, profileKey.String, profileImage})
+	}
+	if backgroundKey.Valid {
+		images = append(images, exportImage{"profile/background_image", backgroundMime.String, backgroundKey.String, nil})
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT id, storage_key, mime_type FROM profile_image_history WHERE user_id = $1 ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var image exportImage
+		if err := rows.Scan(&id, &image.storageKey, &image.mimeType); err == nil {
+			image.name = fmt.Sprintf("profile/history/%d", id)
+			images = append(images, image)
+		}
+	}
+	return profile, images, rows.Err()
+}
+
+func writeUserExport(c *gin.Context, zw *zip.Writer, userID int, profile gin.H, images []exportImage) error {
+	if err := writeExportJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	settings := gin.H{
+		"settings":      services.GetUserSettings(userID),
+		"privacy":       services.GetPrivacySettings(userID),
+		"notifications": services.GetNotificationSettings(userID),
+	}
+	if err := writeExportJSON(zw, "settings.json", settings); err != nil {
+		return err
+	}
+
+	exports := []struct {
+		name  string
+		query string
+	}{
+		{"friends.json", `
+			SELECT json_build_object('user_id', u.id, 'username', u.username, 'name', u.name, 'nickname', f.nickname,
+				'favorited_at', f.favorited_at, 'hidden_at', f.hidden_at, 'created_at', f.created_at)
+			FROM friends f JOIN users u ON u.id = f.friend_id
+			WHERE f.user_id = $1 ORDER BY f.created_at`},
+		{"friend_requests.json", `
+			SELECT json_build_object('id', fr.id, 'requester_id', fr.requester_id, 'recipient_id', fr.recipient_id,
+				'status', fr.status, 'created_at', fr.created_at, 'responded_at', fr.responded_at)
+			FROM friend_requests fr
+			WHERE fr.requester_id = $1 OR fr.recipient_id = $1 ORDER BY fr.created_at`},
+		{"blocks.json", `
+			SELECT json_build_object('user_id', u.id, 'username', u.username, 'name', u.name, 'created_at', ub.created_at)
+			FROM user_blocks ub JOIN users u ON u.id = ub.blocked_id
+			WHERE ub.blocker_id = $1 ORDER BY ub.created_at`},
+		{"contacts.json", `
+			SELECT json_build_object('phone_hash', phone_hash, 'created_at', created_at)
+			FROM user_contacts WHERE user_id = $1 ORDER BY created_at`},
+		{"rooms.json", `
+			SELECT json_build_object('room_id', cr.id, 'type', cr.type, 'name', cr.name, 'role', crm.role,
+				'joined_at', crm.joined_at, 'left_at', crm.left_at)
+			FROM chat_room_members crm JOIN chat_rooms cr ON cr.id = crm.room_id
+			WHERE crm.user_id = $1 ORDER BY crm.joined_at`},
+		{"messages.json", `
+			SELECT json_build_object('id', m.id, 'room_id', m.room_id, 'type', m.type, 'content', m.content,
+				'created_at', m.created_at, 'files', (
+					SELECT json_agg(json_build_object('id', mf.public_id, 'filename', mf.filename,
+						'mime_type', mf.mime_type, 'file_size', mf.file_size) ORDER BY mf.id)
+					FROM message_files mf WHERE mf.message_id = m.id))
+			FROM messages m
+			WHERE m.sender_id = $1 ORDER BY m.created_at, m.id`},
+	}
+	for _, export := range exports {
+		if err := writeExportRows(zw, export.name, export.query, userID); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range images {
+		name := image.name + imageExtension(image.mimeType)
+		if err := writeExportFile(c, zw, name, image.storageKey, image.data); err != nil {
+			return err
+		}
+	}
+
+	return writeExportMessageFiles(c, zw, userID)
+}
+
+// writeExportMessageFiles 는 내가 보낸 메시지의 파일을 files/<파일 ID>/<파일명> 으로 담는다. 격리된 파일은 제외한다.
+func writeExportMessageFiles(c *gin.Context, zw *zip.Writer, userID int) error {
+	rows, err := config.DB.Query(`
+		SELECT mf.public_id::TEXT, mf.filename, mf.storage_key, mf.storage_key IS NULL
+		FROM message_files mf
+		JOIN messages m ON mf.message_id = m.id
+		WHERE m.sender_id = $1
+	`+notQuarantined+`
+		ORDER BY mf.id
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	type exportFile struct {
+		id, filename, storageKey string
+		legacy                   bool
+	}
+	var files []exportFile
+	for rows.Next() {
+		var f exportFile
+		var storageKey sql.NullString
+		if rows.Scan(&f.id, &f.filename, &storageKey, &f.legacy) == nil {
+			f.storageKey = storageKey.String
+			files = append(files, f)
+		}
+	}
+	rows.Close()
+
+	for _, f := range files {
+		// 스토리지로 옮기기 전의 파일은 하나씩 DB에서 읽는다
+		var data []byte
+		if f.legacy {
+			config.DB.QueryRow("SELECT file_data FROM message_files WHERE public_id = $1::UUID", f.id).Scan(&data)
+		}
+		if err := writeExportFile(c, zw, "files/"+f.id+"/"+exportFilename(f.filename), f.storageKey, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExportJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeExportRows 는 한 행에 JSON 하나를 돌려주는 query 결과를 JSON 배열로 담는다. $1은 사용자 ID다.
+func writeExportRows(zw *zip.Writer, name, query string, userID int) error {
+	rows, err := config.DB.Query(query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	io.WriteString(w, "[")
+	first := true
+	for rows.Next() {
+		var row json.RawMessage
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		if !first {
+			io.WriteString(w, ",")
+		}
+		first = false
+		io.WriteString(w, "\n  ")
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	io.WriteString(w, "\n]\n")
+	return rows.Err()
+}
+
+// 스토리지에서 찾을 수 없는 파일은 건너뛴다
+func writeExportFile(c *gin.Context, zw *zip.Writer, name, storageKey string, data []byte) error {
+	var content io.Reader = bytes.NewReader(data)
+	if storageKey != "" {
+		object, _, err := storage.Default.Open(c.Request.Context(), storageKey)
+		if err != nil {
+			log.Printf("Skipping %s in export: %v", storageKey, err)
+			return nil
+		}
+		defer object.Close()
+		content = object
+	}
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+// 압축을 풀 때 경로가 바뀌지 않도록 파일명의 경로 구분자를 없앤다
+func exportFilename(filename string) string {
+	filename = strings.NewReplacer("/", "_", "\\", "_").Replace(filename)
+	if filename == "" || filename == "." || filename == ".." {
+		return "file"
+	}
+	return filename
+}
+
+func imageExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/heic", "image/heif":
+		return ".heic"
+	}
+	return ""
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
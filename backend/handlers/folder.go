@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+func GetFolders(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	rows, err := config.DB.Query(`
+		SELECT f.id, f.name, f.position, f.created_at,
+			(SELECT COUNT(*) FROM chat_room_members
+			 WHERE folder_id = f.id AND user_id = $1 AND left_at IS NULL) as room_count
+		FROM chat_folders f
+		WHERE f.user_id = $1
+		ORDER BY f.position, f.id
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get folders"})
+		return
+	}
+	defer rows.Close()
+
+	var folders []models.ChatFolder
+	for rows.Next() {
+		var f models.ChatFolder
+		if err := rows.Scan(&f.ID, &f.Name, &f.Position, &f.CreatedAt, &f.RoomCount); err == nil {
+			folders = append(folders, f)
+		}
+	}
+
+	if folders == nil {
+		folders = []models.ChatFolder{}
+	}
+
+	c.JSON(http.StatusOK, folders)
+}
+
+func CreateFolder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var folder models.ChatFolder
+	err := config.DB.QueryRow(`
+		INSERT INTO chat_folders (user_id, name, position) VALUES ($1, $2, $3)
+		RETURNING id, name, position, created_at
+	`, userID, req.Name, req.Position).Scan(&folder.ID, &folder.Name, &folder.Position, &folder.CreatedAt)
+
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Folder name already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+func UpdateFolder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	folderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	var req models.FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		UPDATE chat_folders SET name = $1, position = $2 WHERE id = $3 AND user_id = $4
+	`, req.Name, req.Position, folderID, userID)
+
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Folder name already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder updated successfully"})
+}
+
+func DeleteFolder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	folderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	// 폴더 안의 채팅방은 ON DELETE SET NULL로 폴더 밖으로 빠진다
+	result, err := config.DB.Exec(`
+		DELETE FROM chat_folders WHERE id = $1 AND user_id = $2
+	`, folderID, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
+
+func isUniqueViolation(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
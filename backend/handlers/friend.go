@@ -2,11 +2,17 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"messenger/config"
 	"messenger/middleware"
 	"messenger/models"
+	"messenger/push"
+	"messenger/services"
+	"messenger/websocket"
 	"net/http"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,13 +20,17 @@ import (
 func GetFriends(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
+	// 숨긴 친구는 ?hidden=true 일 때만 따로 보여준다
+	hidden := c.Query("hidden") == "true"
+
 	rows, err := config.DB.Query(`
-		SELECT f.id, f.friend_id, u.username, u.name, f.created_at
+		SELECT f.id, f.friend_id, u.username, COALESCE(f.nickname, u.name), COALESCE(f.nickname, ''),
+			f.favorited_at IS NOT NULL, f.hidden_at IS NOT NULL, f.created_at
 		FROM friends f
 		JOIN users u ON f.friend_id = u.id
-		WHERE f.user_id = $1
-		ORDER BY u.name
-	`, userID)
+		WHERE f.user_id = $1 AND (f.hidden_at IS NOT NULL) = $2 AND u.deleted_at IS NULL
+		ORDER BY f.favorited_at IS NULL, COALESCE(f.nickname, u.name)
+	`, userID, hidden)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get friends"})
@@ -31,7 +41,7 @@ func GetFriends(c *gin.Context) {
 	var friends []models.FriendWithUser
 	for rows.Next() {
 		var f models.FriendWithUser
-		if err := rows.Scan(&f.ID, &f.FriendID, &f.Username, &f.Name, &f.CreatedAt); err == nil {
+		if err := rows.Scan(&f.ID, &f.FriendID, &f.Username, &f.Name, &f.Nickname, &f.IsFavorite, &f.IsHidden, &f.CreatedAt); err == nil {
 			friends = append(friends, f)
 		}
 	}
@@ -43,28 +53,89 @@ func GetFriends(c *gin.Context) {
 	c.JSON(http.StatusOK, friends)
 }
 
-func AddFriend(c *gin.Context) {
+func UpdateFriendPreferences(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	var req models.AddFriendRequest
+	friendID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid friend ID"})
+		return
+	}
+
+	var req models.UpdateFriendPreferencesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var friendID int
-	err := config.DB.QueryRow(`
-		SELECT id FROM users WHERE (username = $1 OR phone = $1) AND id != $2
-	`, req.Query, userID).Scan(&friendID)
+	var nickname sql.NullString
+	if req.Nickname != nil {
+		trimmed := strings.TrimSpace(*req.Nickname)
+		if utf8.RuneCountInString(trimmed) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nickname is too long"})
+			return
+		}
+		nickname = sql.NullString{String: trimmed, Valid: trimmed != ""}
+	}
+
+	// nil인 항목은 기존 값을 유지하고, 빈 별명은 별명을 지운다
+	var f models.FriendWithUser
+	err = config.DB.QueryRow(`
+		UPDATE friends f SET
+			favorited_at = CASE
+				WHEN $1::BOOLEAN IS NULL THEN favorited_at
+				WHEN $1 THEN COALESCE(favorited_at, NOW())
+				ELSE NULL END,
+			hidden_at = CASE
+				WHEN $2::BOOLEAN IS NULL THEN hidden_at
+				WHEN $2 THEN COALESCE(hidden_at, NOW())
+				ELSE NULL END,
+			nickname = CASE WHEN $3 THEN $4 ELSE nickname END
+		FROM users u
+		WHERE u.id = f.friend_id AND f.user_id = $5 AND f.friend_id = $6
+		RETURNING f.id, f.friend_id, u.username, COALESCE(f.nickname, u.name), COALESCE(f.nickname, ''),
+			f.favorited_at IS NOT NULL, f.hidden_at IS NOT NULL, f.created_at
+	`, req.IsFavorite, req.IsHidden, req.Nickname != nil, nickname, userID, friendID).Scan(
+		&f.ID, &f.FriendID, &f.Username, &f.Name, &f.Nickname, &f.IsFavorite, &f.IsHidden, &f.CreatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update friend"})
+		return
+	}
+
+	// 다른 기기와 동기화
+	websocket.BroadcastToUser(userID, models.WebSocketMessage{
+		Type:    "friend_updated",
+		Payload: f,
+	})
+
+	c.JSON(http.StatusOK, f)
+}
+
+func AddFriend(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.AddFriendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+
+	// 검색 허용 설정과 차단 관계는 FindUsers 가 반영한다. 찾을 수 없으면 없는 사용자처럼 응답
+	found, err := services.FindUsers(userID, req.Query, 1)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	if len(found) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	friendID := found[0].ID
 
 	var exists bool
 	err = config.DB.QueryRow(`
@@ -76,16 +147,275 @@ func AddFriend(c *gin.Context) {
 		return
 	}
 
-	_, err = config.DB.Exec(`
-		INSERT INTO friends (user_id, friend_id) VALUES ($1, $2)
-	`, userID, friendID)
+	// 상대가 이미 나에게 요청을 보냈다면 그 요청을 수락한 것으로 처리
+	var reverseID int
+	err = config.DB.QueryRow(`
+		SELECT id FROM friend_requests
+		WHERE requester_id = $1 AND recipient_id = $2 AND status = 'pending'
+	`, friendID, userID).Scan(&reverseID)
+	if err == nil {
+		if err := respondFriendRequest(reverseID, userID, "accepted"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add friend"})
+			return
+		}
+		notifyFriendRequestAccepted(reverseID, friendID, userID)
+		c.JSON(http.StatusOK, gin.H{"message": "Friend added successfully", "status": "accepted"})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var requestID int
+	err = config.DB.QueryRow(`
+		INSERT INTO friend_requests (requester_id, recipient_id)
+		VALUES ($1, $2)
+		ON CONFLICT (requester_id, recipient_id) WHERE status = 'pending' DO NOTHING
+		RETURNING id
+	`, userID, friendID).Scan(&requestID)
 
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Friend request already sent"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add friend"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send friend request"})
+		return
+	}
+
+	if services.GetUserSettings(friendID).AutoAcceptFriendRequests {
+		if err := respondFriendRequest(requestID, friendID, "accepted"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add friend"})
+			return
+		}
+		// 요청한 사람이 지금 결과를 받으므로 푸시는 보내지 않는다
+		notifyFriendRequestAccepted(requestID, userID, userID)
+		c.JSON(http.StatusOK, gin.H{"message": "Friend added successfully", "status": "accepted"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Friend added successfully"})
+	if request, err := getFriendRequest(requestID, friendID); err == nil {
+		broadcastFriendRequest(friendID, "received", request)
+		push.NotifyUser(friendID, push.Notification{
+			Title: request.Name,
+			Body:  "친구 요청을 보냈습니다",
+			Data:  map[string]string{"type": "friend_request", "request_id": strconv.Itoa(requestID)},
+		})
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Friend request sent", "status": "pending", "request_id": requestID})
+}
+
+func GetIncomingFriendRequests(c *gin.Context) {
+	listFriendRequests(c, "recipient_id", "requester_id")
+}
+
+func GetOutgoingFriendRequests(c *gin.Context) {
+	listFriendRequests(c, "requester_id", "recipient_id")
+}
+
+func listFriendRequests(c *gin.Context, selfColumn, otherColumn string) {
+	userID := middleware.GetUserID(c)
+
+	rows, err := config.DB.Query(`
+		SELECT fr.id, fr.requester_id, fr.recipient_id, fr.status, u.id, u.username, u.name, fr.created_at, fr.responded_at
+		FROM friend_requests fr
+		JOIN users u ON u.id = fr.`+otherColumn+`
+		WHERE fr.`+selfColumn+` = $1 AND fr.status = 'pending' AND u.deleted_at IS NULL
+		ORDER BY fr.created_at DESC
+	`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get friend requests"})
+		return
+	}
+	defer rows.Close()
+
+	requests := []models.FriendRequest{}
+	for rows.Next() {
+		var r models.FriendRequest
+		if err := rows.Scan(&r.ID, &r.RequesterID, &r.RecipientID, &r.Status, &r.UserID, &r.Username, &r.Name, &r.CreatedAt, &r.RespondedAt); err == nil {
+			requests = append(requests, r)
+		}
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func AcceptFriendRequest(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	request, err := getFriendRequest(requestID, userID)
+	if err != nil || request.RecipientID != userID || request.Status != "pending" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+		return
+	}
+
+	if err := respondFriendRequest(requestID, userID, "accepted"); err == errFriendRequestNotPending {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept friend request"})
+		return
+	}
+
+	notifyFriendRequestAccepted(requestID, request.RequesterID, userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request accepted"})
+}
+
+func DeclineFriendRequest(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		UPDATE friend_requests SET status = 'declined', responded_at = NOW()
+		WHERE id = $1 AND recipient_id = $2 AND status = 'pending'
+	`, requestID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline friend request"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+		return
+	}
+
+	// 거절 사실은 요청자에게 알리지 않고 내 다른 기기만 동기화
+	if request, err := getFriendRequest(requestID, userID); err == nil {
+		broadcastFriendRequest(userID, "declined", request)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request declined"})
+}
+
+func CancelFriendRequest(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var recipientID int
+	err = config.DB.QueryRow(`
+		UPDATE friend_requests SET status = 'canceled', responded_at = NOW()
+		WHERE id = $1 AND requester_id = $2 AND status = 'pending'
+		RETURNING recipient_id
+	`, requestID, userID).Scan(&recipientID)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel friend request"})
+		return
+	}
+
+	if request, err := getFriendRequest(requestID, recipientID); err == nil {
+		broadcastFriendRequest(recipientID, "canceled", request)
+	}
+	if request, err := getFriendRequest(requestID, userID); err == nil {
+		broadcastFriendRequest(userID, "canceled", request)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request canceled"})
+}
+
+var errFriendRequestNotPending = errors.New("friend request is not pending")
+
+// respondFriendRequest 는 받은 요청의 상태를 바꾸고, 수락이면 양방향 친구 관계를 만든다.
+func respondFriendRequest(requestID, recipientID int, status string) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var requesterID int
+	err = tx.QueryRow(`
+		UPDATE friend_requests SET status = $3, responded_at = NOW()
+		WHERE id = $1 AND recipient_id = $2 AND status = 'pending'
+		RETURNING requester_id
+	`, requestID, recipientID, status).Scan(&requesterID)
+	if err == sql.ErrNoRows {
+		return errFriendRequestNotPending
+	}
+	if err != nil {
+		return err
+	}
+
+	if status == "accepted" {
+		if _, err := tx.Exec(`
+			INSERT INTO friends (user_id, friend_id) VALUES ($1, $2), ($2, $1)
+			ON CONFLICT (user_id, friend_id) DO NOTHING
+		`, requesterID, recipientID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// notifyFriendRequestAccepted 는 수락 결과를 양쪽에 알리고, 받는 사람이 직접 수락했으면(acceptedBy) 요청한 사람에게 푸시를 보낸다.
+func notifyFriendRequestAccepted(requestID, requesterID, acceptedBy int) {
+	request, err := getFriendRequest(requestID, requesterID)
+	if err != nil {
+		return
+	}
+	broadcastFriendRequest(requesterID, "accepted", request)
+
+	if mine, err := getFriendRequest(requestID, request.RecipientID); err == nil {
+		broadcastFriendRequest(request.RecipientID, "accepted", mine)
+		if acceptedBy == request.RecipientID {
+			push.NotifyUser(requesterID, push.Notification{
+				Title: mine.Name,
+				Body:  "친구 요청을 수락했습니다",
+				Data:  map[string]string{"type": "friend_request", "request_id": strconv.Itoa(requestID)},
+			})
+		}
+	}
+}
+
+// displayName 은 사용자 u의 이름을 viewerParam 사용자가 지정한 별명으로 바꿔 보여주는 SQL 식이다.
+func displayName(viewerParam string) string {
+	return `COALESCE((SELECT f.nickname FROM friends f WHERE f.user_id = ` + viewerParam + ` AND f.friend_id = u.id), u.name)`
+}
+
+// getFriendRequest 는 viewerID 입장에서 상대방 정보를 채운 요청을 조회한다.
+func getFriendRequest(requestID, viewerID int) (models.FriendRequest, error) {
+	var r models.FriendRequest
+	err := config.DB.QueryRow(`
+		SELECT fr.id, fr.requester_id, fr.recipient_id, fr.status, u.id, u.username, u.name, fr.created_at, fr.responded_at
+		FROM friend_requests fr
+		JOIN users u ON u.id = CASE WHEN fr.requester_id = $2 THEN fr.recipient_id ELSE fr.requester_id END
+		WHERE fr.id = $1 AND (fr.requester_id = $2 OR fr.recipient_id = $2)
+	`, requestID, viewerID).Scan(&r.ID, &r.RequesterID, &r.RecipientID, &r.Status, &r.UserID, &r.Username, &r.Name, &r.CreatedAt, &r.RespondedAt)
+	return r, err
+}
+
+func broadcastFriendRequest(userID int, action string, request models.FriendRequest) {
+	websocket.BroadcastToUser(userID, models.WebSocketMessage{
+		Type: "friend_request",
+		Payload: gin.H{
+			"action":  action,
+			"request": request,
+		},
+	})
 }
 
 func DeleteFriend(c *gin.Context) {
@@ -99,7 +429,8 @@ func DeleteFriend(c *gin.Context) {
 	}
 
 	result, err := config.DB.Exec(`
-		DELETE FROM friends WHERE user_id = $1 AND friend_id = $2
+		DELETE FROM friends
+		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
 	`, userID, friendID)
 
 	if err != nil {
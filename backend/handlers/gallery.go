@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// 모아보기 탭별로 포함하는 메시지 타입
+var galleryMessageTypes = map[string][]string{
+	"image": {"image"},
+	"video": {"video"},
+	"file":  {"file", "audio"},
+}
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// GetRoomMedia 는 채팅방에서 공유된 사진/동영상/파일/링크를 최신순으로 반환한다.
+// cursor는 이전 응답의 next_cursor(메시지 ID)이며, 그보다 오래된 항목을 이어서 준다.
+func GetRoomMedia(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if _, _, err := getMembership(roomID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+
+	mediaType := c.DefaultQuery("type", "image")
+	if _, ok := galleryMessageTypes[mediaType]; !ok && mediaType != "link" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media type"})
+		return
+	}
+
+	limit := 30
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	cursor := 0
+	if cur := c.Query("cursor"); cur != "" {
+		if cursor, err = strconv.Atoi(cur); err != nil || cursor <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	var items []models.MediaItem
+	if mediaType == "link" {
+		items, err = loadLinkItems(roomID, userID, cursor, limit+1)
+	} else {
+		items, err = loadFileItems(roomID, userID, galleryMessageTypes[mediaType], cursor, limit+1)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get media"})
+		return
+	}
+
+	// 한 개 더 읽어 다음 페이지가 있는지 확인한다
+	page := models.MediaPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		next := page.Items[limit-1].MessageID
+		page.NextCursor = &next
+	}
+	if page.Items == nil {
+		page.Items = []models.MediaItem{}
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func loadFileItems(roomID, userID int, messageTypes []string, cursor, limit int) ([]models.MediaItem, error) {
+	rows, err := config.DB.Query(`
+		SELECT m.id, m.type, m.sender_id, `+displayName("$2")+`, m.created_at,
+			mf.public_id::TEXT, mf.filename, mf.file_size, mf.width, mf.height, mf.blurhash, mf.duration_ms
+		FROM message_files mf
+		`+authorizedFileJoin+`
+		LEFT JOIN users u ON m.sender_id = u.id
+		WHERE m.room_id = $1 AND m.type = ANY($3) AND ($4 = 0 OR m.id < $4)
+		ORDER BY m.id DESC, mf.id
+		LIMIT $5
+	`, roomID, userID, pq.Array(messageTypes), cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.MediaItem
+	for rows.Next() {
+		var item models.MediaItem
+		var senderID, width, height, durationMS sql.NullInt64
+		var senderName, blurhash sql.NullString
+
+		if err := rows.Scan(&item.MessageID, &item.Type, &senderID, &senderName, &item.CreatedAt,
+			&item.FileID, &item.Filename, &item.FileSize, &width, &height, &blurhash, &durationMS); err != nil {
+			return nil, err
+		}
+
+		if senderID.Valid {
+			id := int(senderID.Int64)
+			item.SenderID = &id
+		}
+		item.SenderName = senderName.String
+		item.Extension = fileExtension(item.Filename)
+		item.Blurhash = blurhash.String
+		if width.Valid && height.Valid {
+			w, h := int(width.Int64), int(height.Int64)
+			item.Width, item.Height = &w, &h
+		}
+		if durationMS.Valid {
+			d := int(durationMS.Int64)
+			item.DurationMS = &d
+		}
+		if item.Type == "image" {
+			item.ThumbnailURL = "/api/files/" + item.FileID + "?size=small"
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func loadLinkItems(roomID, userID, cursor, limit int) ([]models.MediaItem, error) {
+	rows, err := config.DB.Query(`
+		SELECT m.id, m.sender_id, `+displayName("$2")+`, m.content, m.created_at
+		FROM messages m
+		`+visibleMessageJoin+`
+		LEFT JOIN users u ON m.sender_id = u.id
+		WHERE m.room_id = $1 AND m.type = 'text' AND m.content ~* 'https?://'
+		AND ($3 = 0 OR m.id < $3)
+		ORDER BY m.id DESC
+		LIMIT $4
+	`, roomID, userID, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.MediaItem
+	for rows.Next() {
+		item := models.MediaItem{Type: "link"}
+		var senderID sql.NullInt64
+		var senderName sql.NullString
+
+		if err := rows.Scan(&item.MessageID, &senderID, &senderName, &item.Content, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if senderID.Valid {
+			id := int(senderID.Int64)
+			item.SenderID = &id
+		}
+		item.SenderName = senderName.String
+		item.URLs = linkPattern.FindAllString(item.Content, -1)
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mediaContent struct {
+	Content      io.ReadSeeker
+	ContentType  string
+	ETag         string
+	ModTime      time.Time
+	Filename     string
+	CacheControl string
+}
+
+// serveMedia 는 http.ServeContent로 Range(206), If-None-Match/If-Modified-Since(304),
+// Content-Length를 처리한다. ETag는 호출하는 쪽에서 내용이 바뀌면 달라지는 값을 넘겨야 한다.
+func serveMedia(c *gin.Context, m mediaContent) {
+	header := c.Writer.Header()
+	header.Set("Content-Type", m.ContentType)
+	// 저장된 타입과 다르게 브라우저가 내용을 추측해 실행하지 않도록 한다
+	header.Set("X-Content-Type-Options", "nosniff")
+	if m.ETag != "" {
+		header.Set("ETag", m.ETag)
+	}
+	if m.CacheControl != "" {
+		header.Set("Cache-Control", m.CacheControl)
+	}
+	if m.Filename != "" {
+		disposition := "inline"
+		if m.ContentType == "application/octet-stream" {
+			disposition = "attachment"
+		}
+		header.Set("Content-Disposition", contentDisposition(disposition, m.Filename))
+	}
+
+	http.ServeContent(c.Writer, c.Request, "", m.ModTime, m.Content)
+}
+
+// 한글 등 비ASCII 파일명은 RFC 6266/5987의 filename*로 인코딩하고,
+// 이를 지원하지 않는 클라이언트를 위해 ASCII로 치환한 filename도 함께 보낸다.
+func contentDisposition(disposition, filename string) string {
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r < 0x20 || r == 0x7f || r == '"' || r == '\\':
+			fallback.WriteByte('_')
+		case r > 0x7e:
+			fallback.WriteByte('_')
+			ascii = false
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	value := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback.String())
+	if !ascii {
+		value += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+	return value
+}
+
+// RFC 5987 attr-char 외의 바이트는 모두 퍼센트 인코딩한다
+func encodeExtValue(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+			strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
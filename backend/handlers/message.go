@@ -1,14 +1,22 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"io"
 	"messenger/config"
+	"messenger/media"
 	"messenger/middleware"
 	"messenger/models"
+	"messenger/push"
+	"messenger/services"
+	"messenger/storage"
 	"messenger/websocket"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -48,14 +56,19 @@ func GetMessages(c *gin.Context) {
 	}
 
 	rows, err := config.DB.Query(`
-		SELECT m.id, m.room_id, m.sender_id, u.name, m.content, m.type, m.created_at,
-			(SELECT mf.id FROM message_files mf WHERE mf.message_id = m.id LIMIT 1) as file_id
+		SELECT m.id, m.room_id, COALESCE(m.sender_id, 0), `+displayName("$4")+`, m.content, m.type, m.created_at,
+			mf.public_id::TEXT, mf.filename, mf.file_size, mf.width, mf.height, mf.blurhash, mf.duration_ms, mf.waveform
 		FROM messages m
 		LEFT JOIN users u ON m.sender_id = u.id
+		LEFT JOIN LATERAL (
+			SELECT public_id, filename, file_size, width, height, blurhash, duration_ms, waveform FROM message_files
+			WHERE message_id = m.id ORDER BY id LIMIT 1
+		) mf ON TRUE
 		WHERE m.room_id = $1
+		`+blockedSenderFilter("$4")+`
 		ORDER BY m.created_at DESC
 		LIMIT $2 OFFSET $3
-	`, roomID, limit, offset)
+	`, roomID, limit, offset, userID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
@@ -68,9 +81,13 @@ func GetMessages(c *gin.Context) {
 		var m models.Message
 		var senderName sql.NullString
 		var content sql.NullString
-		var fileID sql.NullInt64
+		var fileID, filename sql.NullString
+		var fileSize, width, height, durationMS sql.NullInt64
+		var blurhash sql.NullString
+		var waveform []byte
 
-		if err := rows.Scan(&m.ID, &m.RoomID, &m.SenderID, &senderName, &content, &m.Type, &m.CreatedAt, &fileID); err == nil {
+		if err := rows.Scan(&m.ID, &m.RoomID, &m.SenderID, &senderName, &content, &m.Type, &m.CreatedAt,
+			&fileID, &filename, &fileSize, &width, &height, &blurhash, &durationMS, &waveform); err == nil {
 			if senderName.Valid {
 				m.SenderName = senderName.String
 			}
@@ -78,8 +95,20 @@ func GetMessages(c *gin.Context) {
 				m.Content = content.String
 			}
 			if fileID.Valid {
-				fid := int(fileID.Int64)
-				m.FileID = &fid
+				m.FileID = &fileID.String
+				if width.Valid && height.Valid {
+					w, h := int(width.Int64), int(height.Int64)
+					m.Width, m.Height = &w, &h
+				}
+				m.Blurhash = blurhash.String
+				m.Filename = filename.String
+				m.FileSize = fileSize.Int64
+				m.Extension = fileExtension(filename.String)
+				if durationMS.Valid {
+					d := int(durationMS.Int64)
+					m.DurationMS = &d
+				}
+				m.Waveform = waveformSamples(waveform)
 			}
 			messages = append(messages, m)
 		}
@@ -150,11 +179,15 @@ func SendMessage(c *gin.Context) {
 		"created_at":  createdAt,
 	}
 
-	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+	unarchiveRoom(roomID, userID)
+
+	websocket.BroadcastMessageToRoom(roomID, userID, models.WebSocketMessage{
 		Type:    "new_message",
 		Payload: message,
 	})
 
+	push.NotifyRoom(roomID, userID, req.Content, newMessageNotification(roomID, messageID, senderName, req.Content))
+
 	c.JSON(http.StatusCreated, message)
 }
 
@@ -178,32 +211,142 @@ func SendFile(c *gin.Context) {
 		return
 	}
 
-	file, header, err := c.Request.FormFile("file")
+	part, fields, err := openUploadPart(c, "file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File required"})
 		return
 	}
-	defer file.Close()
+	defer part.Close()
 
-	fileData, err := io.ReadAll(file)
+	requestedType, upload, err := parseFileOptions(part.FileName(), fields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	mimeType := header.Header.Get("Content-Type")
-	messageType := "image"
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
+	message, ok := storeFileMessage(c, roomID, userID, part, requestedType, upload)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
+
+// parseFileOptions 는 파일과 함께 보낸 필드(type, duration_ms, waveform, original)를 검증한다.
+// 반환하는 오류 메시지는 그대로 클라이언트에 내려준다.
+func parseFileOptions(filename string, fields map[string]string) (string, fileUpload, error) {
+	upload := fileUpload{Filename: filename}
+
+	requestedType := fields["type"]
+	if requestedType != "" && requestedType != "voice" {
+		return "", upload, errors.New("Invalid message type")
+	}
+	upload.KeepMetadata = fields["original"] == "true"
+	if d := fields["duration_ms"]; d != "" {
+		duration, err := strconv.Atoi(d)
+		if err != nil || duration <= 0 {
+			return "", upload, errors.New("Invalid duration_ms")
+		}
+		upload.DurationMS = &duration
+	}
+	if requestedType == "voice" {
+		if upload.DurationMS == nil {
+			return "", upload, errors.New("duration_ms is required for voice messages")
+		}
+		if w := fields["waveform"]; w != "" {
+			waveform, err := parseWaveform(w)
+			if err != nil {
+				return "", upload, errors.New("Invalid waveform")
+			}
+			upload.Waveform = waveform
+		}
+	}
+
+	return requestedType, upload, nil
+}
+
+// storeFileMessage 는 파일 본문을 검증해 스토리지에 저장하고 메시지로 보낸다.
+// 일반 업로드(SendFile)와 이어받기 업로드 완료 시 모두 이 경로를 거친다.
+// 실패하면 응답을 이미 쓴 상태로 false를 반환한다.
+func storeFileMessage(c *gin.Context, roomID, userID int, body io.Reader, requestedType string, upload fileUpload) (gin.H, bool) {
+	sniffed, err := sniffUpload(body, requestedType)
+	if err != nil {
+		if !abortUploadError(c, sniffed, err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		}
+		return nil, false
+	}
+	messageType := sniffed.MessageType
+	upload.Key = storage.NewKey("files")
+	upload.MimeType = sniffed.MimeType
+
+	// 사진의 EXIF(GPS 위치 등)는 기본으로 지운다. original=true면 원본 그대로 보낸다.
+	var content io.Reader = sniffed.Body
+	if messageType == "image" && !upload.KeepMetadata {
+		stripped := media.StripMetadata(sniffed.MimeType, sniffed.Body)
+		defer stripped.Close()
+		content = stripped
 	}
-	if len(mimeType) > 5 && mimeType[:5] == "video" {
-		messageType = "video"
+
+	hasher := services.NewBlobHasher()
+	upload.Size, err = storage.Default.Put(c.Request.Context(), upload.Key, hasher.Reader(content), sniffed.MimeType)
+	if err != nil {
+		if !abortUploadError(c, sniffed, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		}
+		return nil, false
 	}
 
+	// 같은 내용이 이미 저장되어 있으면 기존 객체와 썸네일을 그대로 쓴다
+	blob, existed, err := services.ClaimBlob(c.Request.Context(), hasher.Sum(), upload.Key, upload.Size, upload.MimeType)
+	if err != nil {
+		storage.Default.Delete(context.Background(), upload.Key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return nil, false
+	}
+	upload.Key = blob.StorageKey
+	upload.SHA256 = blob.SHA256
+	upload.Image = blob.Image
+
+	if !checkBlobScan(c, userID, roomID, upload.Filename, &blob) {
+		return nil, false
+	}
+
+	// 디코딩할 수 없는 이미지(HEIC 등)는 썸네일 없이 원본만 보낸다
+	if messageType == "image" && !existed {
+		if info, err := media.ProcessImage(c.Request.Context(), storage.Default, upload.Key); err == nil {
+			upload.Image = &info
+			services.SetBlobImageInfo(blob.SHA256, info)
+		}
+	}
+
+	// 실패해도 참조되지 않은 blob은 정리 작업이 지운다
+	message, err := saveFileMessage(roomID, userID, messageType, upload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send file"})
+		return nil, false
+	}
+
+	return message, true
+}
+
+type fileUpload struct {
+	Key          string
+	SHA256       string
+	Filename     string
+	MimeType     string
+	KeepMetadata bool
+	Size         int64
+	Image        *media.ImageInfo
+	DurationMS   *int
+	Waveform     []byte
+}
+
+// saveFileMessage 는 이미 스토리지에 저장된 업로드를 메시지로 만들고 방에 알린다.
+func saveFileMessage(roomID, userID int, messageType string, upload fileUpload) (gin.H, error) {
 	tx, err := config.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return nil, err
 	}
 	defer tx.Rollback()
 
@@ -215,25 +358,32 @@ func SendFile(c *gin.Context) {
 	`, roomID, userID, messageType).Scan(&messageID)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
-		return
+		return nil, err
 	}
 
-	var fileID int
+	var width, height sql.NullInt64
+	var blurhash sql.NullString
+	if upload.Image != nil {
+		width = sql.NullInt64{Int64: int64(upload.Image.Width), Valid: true}
+		height = sql.NullInt64{Int64: int64(upload.Image.Height), Valid: true}
+		blurhash = sql.NullString{String: upload.Image.Blurhash, Valid: true}
+	}
+
+	var fileID string
 	err = tx.QueryRow(`
-		INSERT INTO message_files (message_id, filename, mime_type, file_data, file_size)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`, messageID, header.Filename, mimeType, fileData, len(fileData)).Scan(&fileID)
+		INSERT INTO message_files (message_id, filename, mime_type, storage_key, blob_sha256, file_size,
+			width, height, blurhash, duration_ms, waveform)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING public_id
+	`, messageID, upload.Filename, upload.MimeType, upload.Key, upload.SHA256, upload.Size,
+		width, height, blurhash, upload.DurationMS, upload.Waveform).Scan(&fileID)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return
+		return nil, err
 	}
 
 	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send file"})
-		return
+		return nil, err
 	}
 
 	var senderName string
@@ -249,38 +399,209 @@ func SendFile(c *gin.Context) {
 		"sender_name": senderName,
 		"type":        messageType,
 		"file_id":     fileID,
-		"filename":    header.Filename,
+		"filename":    upload.Filename,
+		"file_size":   upload.Size,
+		"extension":   fileExtension(upload.Filename),
 		"created_at":  createdAt,
 	}
+	if upload.Image != nil {
+		message["width"] = upload.Image.Width
+		message["height"] = upload.Image.Height
+		message["blurhash"] = upload.Image.Blurhash
+	}
+	if upload.DurationMS != nil {
+		message["duration_ms"] = *upload.DurationMS
+	}
+	if len(upload.Waveform) > 0 {
+		message["waveform"] = waveformSamples(upload.Waveform)
+	}
 
-	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+	unarchiveRoom(roomID, userID)
+
+	websocket.BroadcastMessageToRoom(roomID, userID, models.WebSocketMessage{
 		Type:    "new_message",
 		Payload: message,
 	})
 
-	c.JSON(http.StatusCreated, message)
+	push.NotifyRoom(roomID, userID, "", newMessageNotification(roomID, messageID, senderName, messagePreview(messageType, "")))
+
+	return message, nil
 }
 
+// GetFile 은 요청한 사용자가 메시지를 볼 수 있는 경우에만 파일을 내려준다.
+// 메시지가 해당 멤버의 참여 기간(joined_at ~ left_at) 안에 있어야 한다.
+// 서명된 URL로 온 요청은 서명 발급 시점에 이미 같은 검사를 통과했다.
 func GetFile(c *gin.Context) {
-	fileIDStr := c.Param("id")
+	fileID := c.Param("id")
 
-	fileID, err := strconv.Atoi(fileIDStr)
+	var file models.MessageFile
+	var storageKey sql.NullString
+	var width, height sql.NullInt64
+	var err error
+
+	if c.GetBool("signed_url") {
+		err = config.DB.QueryRow(`
+			SELECT mf.id, mf.filename, mf.mime_type, mf.file_data, mf.storage_key, mf.file_size, mf.width, mf.height, mf.created_at
+			FROM message_files mf
+			WHERE mf.public_id = $1::UUID
+		`+notQuarantined, fileID).Scan(&file.ID, &file.Filename, &file.MimeType, &file.FileData, &storageKey, &file.FileSize, &width, &height, &file.CreatedAt)
+	} else {
+		err = config.DB.QueryRow(`
+			SELECT mf.id, mf.filename, mf.mime_type, mf.file_data, mf.storage_key, mf.file_size, mf.width, mf.height, mf.created_at
+			FROM message_files mf
+			`+authorizedFileJoin+`
+			WHERE mf.public_id = $1::UUID
+		`, fileID, middleware.GetUserID(c)).Scan(&file.ID, &file.Filename, &file.MimeType, &file.FileData, &storageKey, &file.FileSize, &width, &height, &file.CreatedAt)
+	}
+
+	// 권한이 없을 때도 존재 여부를 드러내지 않도록 404로 응답한다
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
 
-	var file models.MessageFile
-	err = config.DB.QueryRow(`
-		SELECT id, filename, mime_type, file_data, file_size
-		FROM message_files WHERE id = $1
-	`, fileID).Scan(&file.ID, &file.Filename, &file.MimeType, &file.FileData, &file.FileSize)
+	// public_id의 내용은 바뀌지 않으므로 오래 캐시해도 된다
+	content := mediaContent{
+		ContentType:  media.SafeContentType(file.MimeType),
+		ETag:         "\"" + fileID + "\"",
+		ModTime:      file.CreatedAt,
+		Filename:     file.Filename,
+		CacheControl: "private, max-age=31536000, immutable",
+	}
+
+	// 스토리지로 옮기기 전의 파일은 DB에서 바로 내려준다 (썸네일 없음)
+	if !storageKey.Valid {
+		content.Content = bytes.NewReader(file.FileData)
+		serveMedia(c, content)
+		return
+	}
+
+	key := storageKey.String
+	if size := c.Query("size"); size != "" && width.Valid && height.Valid {
+		if thumbKey, ok := media.ThumbnailFor(key, size, int(width.Int64), int(height.Int64)); ok {
+			key = thumbKey
+			content.ContentType = "image/jpeg"
+			content.ETag = "\"" + fileID + "-" + size + "\""
+		}
+	}
 
+	object, _, err := storage.Default.Open(c.Request.Context(), key)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
+	defer object.Close()
+
+	content.Content = object
+	serveMedia(c, content)
+}
+
+func GetFileURL(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	fileID := c.Param("id")
+
+	var exists bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM message_files mf
+			`+authorizedFileJoin+`
+			WHERE mf.public_id = $1::UUID
+		)
+	`, fileID, userID).Scan(&exists)
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(config.AppConfig.SignedURLTTLSeconds) * time.Second)
+	query := services.SignFileURL(fileID, expiresAt)
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        "/api/files/" + fileID + "?" + query.Encode(),
+		"expires_at": expiresAt,
+	})
+}
+
+// 파일 조회 권한 조건. $2는 요청한 사용자 ID다.
+var authorizedFileJoin = `
+	JOIN messages m ON mf.message_id = m.id` + visibleMessageJoin + notQuarantined
+
+// 악성 코드 검사에서 격리된 파일은 권한과 관계없이 내려주지 않는다
+const notQuarantined = `
+	AND NOT EXISTS (SELECT 1 FROM blobs b WHERE b.sha256 = mf.blob_sha256 AND b.scan_status = 'infected')
+`
+
+// 사용자가 볼 수 있는 메시지 m의 조건: 방에 참여해 있던 기간에 보냈고,
+// 차단한 사용자가 차단 이후에 보낸 것이 아닌 것. $2는 요청한 사용자 ID다.
+var visibleMessageJoin = `
+	JOIN chat_room_members crm ON crm.room_id = m.room_id AND crm.user_id = $2
+	AND m.created_at >= crm.joined_at
+	AND (crm.left_at IS NULL OR m.created_at <= crm.left_at)` +
+	blockedSenderFilter("$2") + "\n"
+
+// blockedSenderFilter 는 userParam 사용자가 차단한 발신자가 차단 이후에 보낸 메시지 m을 제외한다.
+// 차단 전에 주고받은 메시지는 그대로 남는다.
+func blockedSenderFilter(userParam string) string {
+	return `
+	AND NOT EXISTS (SELECT 1 FROM user_blocks ub WHERE ub.blocker_id = ` + userParam + `
+		AND ub.blocked_id = m.sender_id AND m.created_at >= ub.created_at)`
+}
+
+// 새 메시지가 오면 보관된 채팅방을 다시 목록으로 꺼낸다 (음소거한 방, 발신자를 차단한 사용자는 제외)
+func unarchiveRoom(roomID, senderID int) {
+	rows, err := config.DB.Query(`
+		SELECT user_id FROM chat_room_members crm
+		WHERE room_id = $1 AND archived_at IS NOT NULL AND left_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM user_blocks ub WHERE ub.blocker_id = crm.user_id AND ub.blocked_id = $2)
+	`, roomID, senderID)
+	if err != nil {
+		return
+	}
 
-	c.Header("Content-Disposition", "inline; filename=\""+file.Filename+"\"")
-	c.Data(http.StatusOK, file.MimeType, file.FileData)
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range userIDs {
+		if services.IsRoomMuted(id, roomID) {
+			continue
+		}
+		config.DB.Exec(`
+			UPDATE chat_room_members SET archived_at = NULL WHERE room_id = $1 AND user_id = $2
+		`, roomID, id)
+	}
+}
+
+func newMessageNotification(roomID, messageID int, senderName, body string) push.Notification {
+	return push.Notification{
+		Title: senderName,
+		Body:  body,
+		Data: map[string]string{
+			"type":       "new_message",
+			"room_id":    strconv.Itoa(roomID),
+			"message_id": strconv.Itoa(messageID),
+		},
+	}
+}
+
+func messagePreview(messageType, content string) string {
+	switch messageType {
+	case "image":
+		return "사진을 보냈습니다"
+	case "video":
+		return "동영상을 보냈습니다"
+	case "file":
+		return "파일을 보냈습니다"
+	case "audio":
+		return "오디오를 보냈습니다"
+	case "voice":
+		return "음성 메시지를 보냈습니다"
+	}
+	return content
 }
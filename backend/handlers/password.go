@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"database/sql"
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"messenger/services"
+	"messenger/websocket"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 재설정 토큰 유효 시간(분)
+const passwordResetTokenMinutes = 15
+
+func ChangePassword(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var passwordHash string
+	if err := config.DB.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&passwordHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if !services.CheckPassword(req.CurrentPassword, passwordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := services.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	// 다른 기기의 세션은 모두 끊고, 지금 기기에는 새 토큰을 준다
+	var username string
+	var tokenVersion int
+	err = config.DB.QueryRow(`
+		UPDATE users SET password_hash = $1, token_version = token_version + 1, updated_at = NOW() WHERE id = $2
+		RETURNING username, token_version
+	`, hashedPassword, userID).Scan(&username, &tokenVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	websocket.DisconnectUser(userID)
+
+	tokenString, err := issueToken(userID, username, tokenVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully", "token": tokenString})
+}
+
+// SendPasswordResetCode 는 가입된 번호일 때만 코드를 보내지만, 가입 여부를 드러내지 않도록 항상 같은 응답을 준다.
+func SendPasswordResetCode(c *gin.Context) {
+	var req models.SendCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 가입 여부와 관계없이 한도를 적용해야 응답으로 가입 여부가 드러나지 않는다
+	if !reserveSMSSend(c, req.Phone) {
+		return
+	}
+
+	var exists bool
+	config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE phone = $1)", req.Phone).Scan(&exists)
+
+	if exists {
+		code := services.GenerateVerificationCode()
+
+		if err := services.SaveVerificationCode(req.Phone, code); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save verification code"})
+			return
+		}
+
+		if err := services.SendSMS(req.Phone, code); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send SMS"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent"})
+}
+
+// VerifyPasswordReset 는 SMS 코드로 번호 소유를 확인하고 한 번만 쓸 수 있는 재설정 토큰을 발급한다.
+func VerifyPasswordReset(c *gin.Context) {
+	var req models.VerifyCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !verifyCodeAttempt(c, req.Phone, req.Code) {
+		return
+	}
+
+	var userID int
+	err := config.DB.QueryRow("SELECT id FROM users WHERE phone = $1", req.Phone).Scan(&userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification code"})
+		return
+	}
+
+	// 같은 코드로 토큰을 여러 번 받지 못하게 한다
+	if err := services.ConsumeVerificationCode(req.Phone, req.Code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	token, err := services.GenerateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token"})
+		return
+	}
+
+	var expiresAt sql.NullTime
+	err = config.DB.QueryRow(`
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() + make_interval(mins => $3))
+		RETURNING expires_at
+	`, userID, services.HashToken(token), passwordResetTokenMinutes).Scan(&expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reset_token": token, "expires_at": expiresAt.Time})
+}
+
+// CompletePasswordReset 은 새 비밀번호를 설정하고 기존 세션(토큰, 웹소켓, 푸시 토큰)을 모두 끊는다.
+func CompletePasswordReset(c *gin.Context) {
+	var req models.CompletePasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := services.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`
+		UPDATE password_resets SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, services.HashToken(req.ResetToken)).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET password_hash = $1, token_version = token_version + 1, updated_at = NOW() WHERE id = $2
+	`, hashedPassword, userID)
+	if err == nil {
+		// 아직 쓰지 않은 다른 재설정 토큰도 함께 무효화
+		_, err = tx.Exec(`
+			UPDATE password_resets SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL
+		`, userID)
+	}
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM push_tokens WHERE user_id = $1", userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	websocket.DisconnectUser(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
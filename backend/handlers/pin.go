@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"database/sql"
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"messenger/websocket"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PinMessage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	roomType, role, err := getMembership(roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+	if !canManageRoom(roomType, role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can pin messages"})
+		return
+	}
+
+	var req models.PinMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exists bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND room_id = $2)
+	`, req.MessageID, roomID).Scan(&exists)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	// 최대 개수를 넘지 않을 때만 삽입한다
+	result, err := config.DB.Exec(`
+		INSERT INTO pinned_messages (room_id, message_id, pinned_by)
+		SELECT $1, $2, $3
+		WHERE (SELECT COUNT(*) FROM pinned_messages WHERE room_id = $1) < $4
+		ON CONFLICT (room_id, message_id) DO NOTHING
+	`, roomID, req.MessageID, userID, config.AppConfig.MaxPinnedMessages)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin message"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		var alreadyPinned bool
+		config.DB.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM pinned_messages WHERE room_id = $1 AND message_id = $2)
+		`, roomID, req.MessageID).Scan(&alreadyPinned)
+		if alreadyPinned {
+			c.JSON(http.StatusConflict, gin.H{"error": "Message already pinned"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Pinned message limit reached",
+			"limit": config.AppConfig.MaxPinnedMessages,
+		})
+		return
+	}
+
+	pinned := loadPinnedMessages(roomID)
+
+	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+		Type: "message_pinned",
+		Payload: gin.H{
+			"room_id":         roomID,
+			"message_id":      req.MessageID,
+			"pinned_by":       userID,
+			"pinned_messages": pinned,
+		},
+	})
+
+	c.JSON(http.StatusCreated, pinned)
+}
+
+func UnpinMessage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	messageID, err := strconv.Atoi(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	roomType, role, err := getMembership(roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+	if !canManageRoom(roomType, role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can unpin messages"})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		DELETE FROM pinned_messages WHERE room_id = $1 AND message_id = $2
+	`, roomID, messageID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpin message"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pinned message not found"})
+		return
+	}
+
+	pinned := loadPinnedMessages(roomID)
+
+	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+		Type: "message_unpinned",
+		Payload: gin.H{
+			"room_id":         roomID,
+			"message_id":      messageID,
+			"unpinned_by":     userID,
+			"pinned_messages": pinned,
+		},
+	})
+
+	c.JSON(http.StatusOK, pinned)
+}
+
+func SetAnnouncement(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	roomType, role, err := getMembership(roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+	if !canManageRoom(roomType, role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can set the announcement"})
+		return
+	}
+
+	var req models.SetAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// 새 공지는 새 ID를 받아 이전 공지의 닫기 기록이 함께 삭제된다
+	_, err = tx.Exec("DELETE FROM room_announcements WHERE room_id = $1", roomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set announcement"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO room_announcements (room_id, content, created_by) VALUES ($1, $2, $3)
+	`, roomID, req.Content, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set announcement"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set announcement"})
+		return
+	}
+
+	announcement := loadAnnouncement(roomID, userID)
+
+	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+		Type: "announcement_updated",
+		Payload: gin.H{
+			"room_id":      roomID,
+			"announcement": announcement,
+		},
+	})
+
+	c.JSON(http.StatusOK, announcement)
+}
+
+func DeleteAnnouncement(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	roomType, role, err := getMembership(roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+	if !canManageRoom(roomType, role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can remove the announcement"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM room_announcements WHERE room_id = $1", roomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove announcement"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found"})
+		return
+	}
+
+	websocket.BroadcastToRoom(roomID, models.WebSocketMessage{
+		Type: "announcement_removed",
+		Payload: gin.H{
+			"room_id":    roomID,
+			"removed_by": userID,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Announcement removed successfully"})
+}
+
+func DismissAnnouncement(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	roomID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if _, _, err := getMembership(roomID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		INSERT INTO announcement_dismissals (announcement_id, user_id)
+		SELECT id, $2 FROM room_announcements WHERE room_id = $1
+		ON CONFLICT (announcement_id, user_id) DO NOTHING
+	`, roomID, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss announcement"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 && loadAnnouncement(roomID, userID) == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dismissed": true})
+}
+
+func loadPinnedMessages(roomID int) []models.PinnedMessage {
+	pinned := []models.PinnedMessage{}
+
+	rows, err := config.DB.Query(`
+		SELECT pm.message_id, pm.room_id, COALESCE(m.sender_id, 0), u.name, m.content, m.type, m.created_at,
+			COALESCE(pm.pinned_by, 0), pm.pinned_at
+		FROM pinned_messages pm
+		JOIN messages m ON pm.message_id = m.id
+		LEFT JOIN users u ON m.sender_id = u.id
+		WHERE pm.room_id = $1
+		ORDER BY pm.pinned_at DESC
+	`, roomID)
+
+	if err != nil {
+		return pinned
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.PinnedMessage
+		var senderName sql.NullString
+		var content sql.NullString
+		if err := rows.Scan(&p.MessageID, &p.RoomID, &p.SenderID, &senderName, &content, &p.Type, &p.CreatedAt, &p.PinnedBy, &p.PinnedAt); err == nil {
+			if senderName.Valid {
+				p.SenderName = senderName.String
+			}
+			if content.Valid {
+				p.Content = content.String
+			}
+			pinned = append(pinned, p)
+		}
+	}
+
+	return pinned
+}
+
+func loadAnnouncement(roomID, userID int) *models.RoomAnnouncement {
+	var a models.RoomAnnouncement
+	var createdByName sql.NullString
+
+	err := config.DB.QueryRow(`
+		SELECT ra.id, ra.room_id, ra.content, COALESCE(ra.created_by, 0), u.name, ra.created_at,
+			EXISTS(SELECT 1 FROM announcement_dismissals WHERE announcement_id = ra.id AND user_id = $2)
+		FROM room_announcements ra
+		LEFT JOIN users u ON ra.created_by = u.id
+		WHERE ra.room_id = $1
+	`, roomID, userID).Scan(&a.ID, &a.RoomID, &a.Content, &a.CreatedBy, &createdByName, &a.CreatedAt, &a.Dismissed)
+
+	if err != nil {
+		return nil
+	}
+
+	if createdByName.Valid {
+		a.CreatedByName = createdByName.String
+	}
+
+	return &a
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"messenger/services"
+	"messenger/websocket"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 사용자별로 보관하는 지난 프로필 사진 수
+const maxProfileHistory = 20
+
+func GetUserProfile(c *gin.Context) {
+	viewerID := middleware.GetUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// 차단 관계에서는 없는 사용자처럼 응답
+	if viewerID != userID && services.IsBlocked(viewerID, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var profile models.UserProfile
+	var statusMessage sql.NullString
+	err = config.DB.QueryRow(`
+		SELECT u.id, u.username, `+displayName("$2")+`, u.status_message,
+			u.profile_image IS NOT NULL OR u.profile_image_key IS NOT NULL, u.background_image_key IS NOT NULL, u.updated_at
+		FROM users u WHERE u.id = $1 AND u.deleted_at IS NULL
+	`, userID, viewerID).Scan(&profile.ID, &profile.Username, &profile.Name, &statusMessage,
+		&profile.HasProfileImage, &profile.HasBackgroundImage, &profile.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+
+	settings := services.GetUserSettings(userID)
+	profile.IsFriend = services.IsFriend(viewerID, userID)
+
+	if services.CanView(viewerID, userID, settings.StatusMessageVisibility) {
+		profile.StatusMessage = statusMessage.String
+	}
+	if !services.CanView(viewerID, userID, settings.ProfileImageVisibility) {
+		profile.HasProfileImage = false
+	}
+	if !services.CanView(viewerID, userID, settings.BackgroundImageVisibility) {
+		profile.HasBackgroundImage = false
+	}
+	if services.CanView(viewerID, userID, settings.ProfileHistoryVisibility) {
+		profile.ProfileHistory = loadProfileHistory(userID)
+	}
+	if presence := loadPresence(viewerID, userID); presence.LastSeenVisible {
+		profile.Online = presence.Online
+		profile.LastSeenAt = presence.LastSeenAt
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func loadProfileHistory(userID int) []models.ProfileHistoryItem {
+	items := []models.ProfileHistoryItem{}
+
+	rows, err := config.DB.Query(`
+		SELECT id, width, height, created_at FROM profile_image_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return items
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.ProfileHistoryItem
+		var width, height sql.NullInt64
+		if err := rows.Scan(&item.ID, &width, &height, &item.CreatedAt); err != nil {
+			continue
+		}
+		if width.Valid && height.Valid {
+			w, h := int(width.Int64), int(height.Int64)
+			item.Width, item.Height = &w, &h
+		}
+		item.URL = fmt.Sprintf("/api/users/%d/profile-history/%d", userID, item.ID)
+		items = append(items, item)
+	}
+	return items
+}
+
+func GetProfileHistoryImage(c *gin.Context) {
+	viewerID := middleware.GetUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if !services.CanView(viewerID, userID, services.GetUserSettings(userID).ProfileHistoryVisibility) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	var key, mimeType string
+	var width, height sql.NullInt64
+	var createdAt time.Time
+	err = config.DB.QueryRow(`
+		SELECT storage_key, mime_type, width, height, created_at FROM profile_image_history
+		WHERE id = $1 AND user_id = $2
+	`, c.Param("historyId"), userID).Scan(&key, &mimeType, &width, &height, &createdAt)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	serveStoredImage(c, key, mimeType, width, height, createdAt)
+}
+
+func DeleteProfileHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	historyID, err := strconv.Atoi(c.Param("historyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid history ID"})
+		return
+	}
+
+	// 참조가 사라진 blob은 트리거와 정리 작업이 지운다
+	result, err := config.DB.Exec(`
+		DELETE FROM profile_image_history WHERE id = $1 AND user_id = $2
+	`, historyID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete profile history"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile history not found"})
+		return
+	}
+
+	notifyProfileUpdated(userID, "profile_history")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Profile history deleted"})
+}
+
+func UpdateBackgroundImage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	blob, info, ok := storeImageUpload(c, userID)
+	if !ok {
+		return
+	}
+
+	_, err := config.DB.Exec(`
+		UPDATE users SET background_image_key = $1, background_image_sha256 = $2, background_image_mime = $3,
+			background_image_width = $4, background_image_height = $5, updated_at = NOW()
+		WHERE id = $6
+	`, blob.StorageKey, blob.SHA256, blob.MimeType, info.Width, info.Height, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update background image"})
+		return
+	}
+
+	notifyProfileUpdated(userID, "background_image")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Background image updated successfully"})
+}
+
+func DeleteBackgroundImage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	_, err := config.DB.Exec(`
+		UPDATE users SET background_image_key = NULL, background_image_sha256 = NULL, background_image_mime = NULL,
+			background_image_width = NULL, background_image_height = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete background image"})
+		return
+	}
+
+	notifyProfileUpdated(userID, "background_image")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Background image deleted"})
+}
+
+func GetBackgroundImage(c *gin.Context) {
+	viewerID := middleware.GetUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if !services.CanView(viewerID, userID, services.GetUserSettings(userID).BackgroundImageVisibility) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Background image not found"})
+		return
+	}
+
+	var key, mimeType sql.NullString
+	var width, height sql.NullInt64
+	var updatedAt time.Time
+	err = config.DB.QueryRow(`
+		SELECT background_image_key, background_image_mime, background_image_width, background_image_height, updated_at
+		FROM users WHERE id = $1
+	`, userID).Scan(&key, &mimeType, &width, &height, &updatedAt)
+
+	if err != nil || !key.Valid {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Background image not found"})
+		return
+	}
+
+	serveStoredImage(c, key.String, mimeType.String, width, height, updatedAt)
+}
+
+func GetPresence(c *gin.Context) {
+	viewerID := middleware.GetUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loadPresence(viewerID, userID))
+}
+
+func loadPresence(viewerID, userID int) models.Presence {
+	presence := models.Presence{UserID: userID}
+	if !services.CanSeeLastSeen(viewerID, userID) {
+		return presence
+	}
+
+	var lastSeen sql.NullTime
+	if err := config.DB.QueryRow("SELECT last_seen_at FROM users WHERE id = $1", userID).Scan(&lastSeen); err != nil {
+		return presence
+	}
+
+	online := websocket.IsUserOnline(userID)
+	presence.LastSeenVisible = true
+	presence.Online = &online
+	if lastSeen.Valid {
+		presence.LastSeenAt = &lastSeen.Time
+	}
+	return presence
+}
+
+// notifyProfileUpdated 는 나를 친구로 둔 사용자와 내 다른 기기에 프로필 변경을 알린다.
+// 바뀐 항목 이름만 보내고, 실제 내용은 공개 범위를 적용하는 GetUserProfile 로 다시 받아가게 한다.
+func notifyProfileUpdated(userID int, fields ...string) {
+	message := models.WebSocketMessage{
+		Type: "profile_updated",
+		Payload: gin.H{
+			"user_id": userID,
+			"fields":  fields,
+		},
+	}
+
+	websocket.BroadcastToUser(userID, message)
+
+	rows, err := config.DB.Query("SELECT user_id FROM friends WHERE friend_id = $1", userID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var friendID int
+		if err := rows.Scan(&friendID); err == nil {
+			websocket.BroadcastToUser(friendID, message)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterPushToken(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.PushTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 기기의 로그인 계정이 바뀌면 토큰 소유자도 바뀐다
+	_, err := config.DB.Exec(`
+		INSERT INTO push_tokens (user_id, platform, token) VALUES ($1, $2, $3)
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, updated_at = NOW()
+	`, userID, req.Platform, req.Token)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register push token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Push token registered successfully"})
+}
+
+func UnregisterPushToken(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req models.DeletePushTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		DELETE FROM push_tokens WHERE user_id = $1 AND token = $2
+	`, userID, req.Token)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unregister push token"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Push token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Push token unregistered successfully"})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"messenger/config"
+	"messenger/middleware"
+	"messenger/models"
+	"messenger/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 추천 점수 가중치: 연락처에 있는 사람 > 함께 아는 친구 > 같은 그룹 채팅방
+const (
+	contactMatchWeight = 5
+	mutualFriendWeight = 2
+	sharedGroupWeight  = 1
+)
+
+func GetFriendRecommendations(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	// 후보마다 (출처) 행을 하나씩 만들고 한 번에 집계한다
+	rows, err := config.DB.Query(`
+		WITH candidates AS (
+			SELECT f2.friend_id AS user_id, 'mutual' AS source
+			FROM friends f1
+			JOIN friends f2 ON f2.user_id = f1.friend_id
+			WHERE f1.user_id = $1
+			UNION ALL
+			SELECT other.user_id, 'group'
+			FROM chat_room_members me
+			JOIN chat_rooms cr ON cr.id = me.room_id AND cr.type = 'group'
+			JOIN chat_room_members other ON other.room_id = me.room_id AND other.left_at IS NULL
+			WHERE me.user_id = $1 AND me.left_at IS NULL
+			UNION ALL
+			SELECT u.id, 'contact'
+			FROM user_contacts uc
+			JOIN users u ON u.phone_hash = uc.phone_hash
+			WHERE uc.user_id = $1 AND `+services.DiscoverableByPhoneCondition("u")+`
+		),
+		scored AS (
+			SELECT user_id,
+				COUNT(*) FILTER (WHERE source = 'mutual') AS mutual_friends,
+				COUNT(*) FILTER (WHERE source = 'group') AS shared_groups,
+				BOOL_OR(source = 'contact') AS in_contacts
+			FROM candidates
+			WHERE user_id != $1
+			GROUP BY user_id
+		)
+		SELECT u.id, u.username, u.name, s.mutual_friends, s.shared_groups, s.in_contacts,
+			s.mutual_friends * $3 + s.shared_groups * $4 + CASE WHEN s.in_contacts THEN $5 ELSE 0 END AS score
+		FROM scored s
+		JOIN users u ON u.id = s.user_id
+		WHERE u.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM friends f WHERE f.user_id = $1 AND f.friend_id = s.user_id)
+		AND NOT EXISTS (SELECT 1 FROM friend_recommendation_dismissals d WHERE d.user_id = $1 AND d.dismissed_user_id = s.user_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM friend_requests fr
+			WHERE fr.status = 'pending'
+			AND ((fr.requester_id = $1 AND fr.recipient_id = s.user_id) OR (fr.requester_id = s.user_id AND fr.recipient_id = $1))
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks ub
+			WHERE (ub.blocker_id = $1 AND ub.blocked_id = s.user_id) OR (ub.blocker_id = s.user_id AND ub.blocked_id = $1)
+		)
+		ORDER BY score DESC, u.name
+		LIMIT $2
+	`, userID, limit, mutualFriendWeight, sharedGroupWeight, contactMatchWeight)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recommendations"})
+		return
+	}
+	defer rows.Close()
+
+	recommendations := []models.FriendRecommendation{}
+	for rows.Next() {
+		var r models.FriendRecommendation
+		if err := rows.Scan(&r.UserID, &r.Username, &r.Name, &r.MutualFriends, &r.SharedGroups, &r.InContacts, &r.Score); err == nil {
+			recommendations = append(recommendations, r)
+		}
+	}
+
+	c.JSON(http.StatusOK, recommendations)
+}
+
+func DismissFriendRecommendation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	dismissedID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil || dismissedID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var exists bool
+	config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", dismissedID).Scan(&exists)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		INSERT INTO friend_recommendation_dismissals (user_id, dismissed_user_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, dismissed_user_id) DO NOTHING
+	`, userID, dismissedID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss recommendation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recommendation dismissed"})
+}
@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"messenger/config"
+	"messenger/middleware"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tus 1.0.0 이어받기 업로드 (https://tus.io/protocols/resumable-upload)
+// 지원 확장: creation, termination, expiration
+const tusVersion = "1.0.0"
+
+// 같은 업로드에 PATCH가 동시에 들어오면 오프셋이 꼬이므로 하나만 처리한다
+var uploadLocks sync.Map
+
+type tusUpload struct {
+	ID        string
+	UserID    int
+	RoomID    int
+	Filename  string
+	Length    int64
+	Offset    int64
+	Metadata  map[string]string
+	MessageID sql.NullInt64
+	ExpiresAt time.Time
+}
+
+// TusOptions 는 OPTIONS 요청에 서버가 지원하는 tus 버전과 확장을 알린다.
+// CORS 미들웨어가 OPTIONS를 먼저 가로채므로 main에서 직접 호출한다.
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,termination,expiration")
+	c.Header("Tus-Max-Size", strconv.FormatInt(maxAnyUploadSize(), 10))
+}
+
+// tus 요청은 Tus-Resumable 헤더로 프로토콜 버전을 밝혀야 한다
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported tus version"})
+		return false
+	}
+	return true
+}
+
+func CreateUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	userID := middleware.GetUserID(c)
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length"})
+		return
+	}
+	if length > maxAnyUploadSize() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large", "max_size": maxAnyUploadSize()})
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Metadata"})
+		return
+	}
+
+	roomID, err := strconv.Atoi(metadata["room_id"])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "room_id metadata required"})
+		return
+	}
+	if _, _, err := getMembership(roomID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return
+	}
+
+	filename := metadata["filename"]
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename metadata required"})
+		return
+	}
+
+	// 완료 시점에 거부되지 않도록 메시지 옵션은 미리 검증한다
+	if _, _, err := parseFileOptions(filename, metadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	metadataJSON, _ := json.Marshal(metadata)
+	expiresAt := uploadExpiry()
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+	defer tx.Rollback()
+
+	// 미완료 업로드는 임시 디렉터리에 쌓이므로 사용자별 개수와 크기 합계를 제한한다.
+	// 같은 사용자의 동시 요청이 한도를 함께 넘지 않도록 사용자 행을 잠근다.
+	var pendingCount int
+	var pendingBytes int64
+	_, err = tx.Exec("SELECT 1 FROM users WHERE id = $1 FOR UPDATE", userID)
+	if err == nil {
+		err = tx.QueryRow(`
+			SELECT COUNT(*), COALESCE(SUM(upload_length), 0) FROM uploads
+			WHERE user_id = $1 AND message_id IS NULL AND expires_at >= NOW()
+		`, userID).Scan(&pendingCount, &pendingBytes)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	if pendingCount >= config.AppConfig.MaxPendingUploads {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many unfinished uploads", "max_uploads": config.AppConfig.MaxPendingUploads})
+		return
+	}
+	maxPendingBytes := int64(config.AppConfig.MaxPendingUploadMB) << 20
+	if pendingBytes+length > maxPendingBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Unfinished uploads too large", "max_size": maxPendingBytes - pendingBytes})
+		return
+	}
+
+	var id string
+	err = tx.QueryRow(`
+		INSERT INTO uploads (user_id, room_id, filename, upload_length, metadata, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, userID, roomID, filename, length, metadataJSON, expiresAt).Scan(&id)
+
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	if err := os.MkdirAll(config.AppConfig.UploadTempDir, 0o755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.Header("Location", "/api/uploads/"+id)
+	c.Header("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+func GetUploadOffset(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	upload, ok := loadUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	writeUploadHeaders(c, upload)
+	c.Status(http.StatusOK)
+}
+
+func PatchUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	if c.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Offset"})
+		return
+	}
+
+	upload, ok := loadUpload(c)
+	if !ok {
+		return
+	}
+
+	lock, _ := uploadLocks.LoadOrStore(upload.ID, &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		c.JSON(http.StatusLocked, gin.H{"error": "Upload is in progress"})
+		return
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	// 잠금을 얻는 사이에 다른 요청이 오프셋을 옮겼을 수 있다
+	if upload, ok = loadUpload(c); !ok {
+		return
+	}
+
+	if upload.MessageID.Valid {
+		writeUploadHeaders(c, upload)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if offset != upload.Offset {
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset mismatch"})
+		return
+	}
+
+	if upload.Offset < upload.Length {
+		written, err := appendUploadChunk(upload, c.Request.Body)
+		upload.Offset += written
+
+		// 연결이 끊겨도 받은 만큼은 기록해 두어 다음 PATCH에서 이어 받는다
+		upload.ExpiresAt = uploadExpiry()
+		if _, dbErr := config.DB.Exec(`
+			UPDATE uploads SET upload_offset = $1, updated_at = NOW(), expires_at = $2 WHERE id = $3
+		`, upload.Offset, upload.ExpiresAt, upload.ID); dbErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save upload"})
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save upload"})
+			return
+		}
+	}
+
+	if upload.Offset == upload.Length && !completeUpload(c, &upload) {
+		return
+	}
+
+	writeUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+func DeleteUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	upload, ok := loadUpload(c)
+	if !ok {
+		return
+	}
+
+	removeUpload(upload.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// loadUpload 는 요청한 사용자의 업로드를 읽는다. 없거나 만료되었으면 응답을 쓰고 false를 반환한다.
+func loadUpload(c *gin.Context) (tusUpload, bool) {
+	var upload tusUpload
+	var metadataJSON []byte
+	var expired bool
+
+	err := config.DB.QueryRow(`
+		SELECT id, user_id, room_id, filename, upload_length, upload_offset, metadata, message_id,
+			expires_at, expires_at < NOW()
+		FROM uploads
+		WHERE id = $1::UUID AND user_id = $2
+	`, c.Param("id"), middleware.GetUserID(c)).Scan(&upload.ID, &upload.UserID, &upload.RoomID, &upload.Filename,
+		&upload.Length, &upload.Offset, &metadataJSON, &upload.MessageID, &upload.ExpiresAt, &expired)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return upload, false
+	}
+
+	if expired {
+		c.JSON(http.StatusGone, gin.H{"error": "Upload expired"})
+		return upload, false
+	}
+
+	// TIMESTAMP 컬럼은 서버 로컬 시각으로 저장되므로 시각 그대로 로컬 시간대로 해석한다
+	e := upload.ExpiresAt
+	upload.ExpiresAt = time.Date(e.Year(), e.Month(), e.Day(), e.Hour(), e.Minute(), e.Second(), e.Nanosecond(), time.Local)
+
+	json.Unmarshal(metadataJSON, &upload.Metadata)
+	return upload, true
+}
+
+func writeUploadHeaders(c *gin.Context, upload tusUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.MessageID.Valid {
+		c.Header("Upload-Message-Id", strconv.FormatInt(upload.MessageID.Int64, 10))
+	}
+}
+
+func uploadPath(id string) string {
+	return filepath.Join(config.AppConfig.UploadTempDir, id)
+}
+
+// appendUploadChunk 는 청크를 임시 파일 끝에 붙이고 실제로 기록한 바이트 수를 반환한다.
+func appendUploadChunk(upload tusUpload, body io.Reader) (int64, error) {
+	f, err := os.OpenFile(uploadPath(upload.ID), os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// 이전 PATCH가 DB 갱신 전에 중단되었다면 기록된 오프셋 뒤의 바이트는 버린다
+	if err := f.Truncate(upload.Offset); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(upload.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(f, io.LimitReader(body, upload.Length-upload.Offset))
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	return written, err
+}
+
+// completeUpload 는 다 받은 파일을 SendFile과 같은 경로로 메시지로 만든다.
+// 검증에 실패한 업로드는 다시 시도해도 소용이 없으므로 지운다.
+func completeUpload(c *gin.Context, upload *tusUpload) bool {
+	if _, _, err := getMembership(upload.RoomID, upload.UserID); err != nil {
+		removeUpload(upload.ID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this room"})
+		return false
+	}
+
+	requestedType, file, err := parseFileOptions(upload.Filename, upload.Metadata)
+	if err != nil {
+		removeUpload(upload.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	f, err := os.Open(uploadPath(upload.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read upload"})
+		return false
+	}
+	defer f.Close()
+
+	message, ok := storeFileMessage(c, upload.RoomID, upload.UserID, f, requestedType, file)
+	if !ok {
+		switch c.Writer.Status() {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+			removeUpload(upload.ID)
+		}
+		return false
+	}
+
+	messageID := message["id"].(int)
+	upload.MessageID = sql.NullInt64{Int64: int64(messageID), Valid: true}
+	config.DB.Exec("UPDATE uploads SET message_id = $1, updated_at = NOW() WHERE id = $2", messageID, upload.ID)
+	os.Remove(uploadPath(upload.ID))
+
+	return true
+}
+
+func removeUpload(id string) {
+	config.DB.Exec("DELETE FROM uploads WHERE id = $1", id)
+	if err := os.Remove(uploadPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove upload %s: %v", id, err)
+	}
+	uploadLocks.Delete(id)
+}
+
+func uploadExpiry() time.Time {
+	return time.Now().Add(time.Duration(config.AppConfig.UploadExpiryHours) * time.Hour)
+}
+
+// Upload-Metadata 는 "key base64값" 쌍을 쉼표로 구분한다 (값은 생략 가능)
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// StartUploadCleanup 은 만료된(중단되었거나 완료된) 업로드와 임시 파일을 주기적으로 지운다.
+func StartUploadCleanup() {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for {
+			cleanupExpiredUploads()
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanupExpiredUploads() {
+	rows, err := config.DB.Query("SELECT id FROM uploads WHERE expires_at < NOW()")
+	if err != nil {
+		log.Printf("Failed to query expired uploads: %v", err)
+		return
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if lock, ok := uploadLocks.Load(id); ok && !lock.(*sync.Mutex).TryLock() {
+			continue
+		} else if ok {
+			lock.(*sync.Mutex).Unlock()
+		}
+		removeUpload(id)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"messenger/config"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   map[string]string
+		err    bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"pairs", "filename 7IKs7KeELmpwZw==,room_id MTI=", map[string]string{"filename": "사진.jpg", "room_id": "12"}, false},
+		{"spaces around pairs", " filename YS50eHQ= , room_id MQ== ", map[string]string{"filename": "a.txt", "room_id": "1"}, false},
+		{"key without value", "room_id MQ==,is_confidential", map[string]string{"room_id": "1", "is_confidential": ""}, false},
+		{"invalid base64", "filename not-base64!", nil, true},
+		{"empty key", "room_id MQ==,", nil, true},
+		{"only comma", ",", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUploadMetadata(tt.header)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("metadata = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func useUploadTempDir(t *testing.T) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig.UploadTempDir = t.TempDir()
+	config.AppConfig.UploadExpiryHours = 24
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+func readUpload(t *testing.T, id string) string {
+	t.Helper()
+	data, err := os.ReadFile(uploadPath(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestAppendUploadChunkResumes(t *testing.T) {
+	useUploadTempDir(t)
+	upload := tusUpload{ID: "resume", Length: 10}
+
+	written, err := appendUploadChunk(upload, strings.NewReader("hello"))
+	if err != nil || written != 5 {
+		t.Fatalf("first chunk = %d, %v", written, err)
+	}
+	upload.Offset += written
+
+	written, err = appendUploadChunk(upload, strings.NewReader("world"))
+	if err != nil || written != 5 {
+		t.Fatalf("second chunk = %d, %v", written, err)
+	}
+	if got := readUpload(t, upload.ID); got != "helloworld" {
+		t.Fatalf("contents = %q", got)
+	}
+}
+
+// PATCH가 파일에 쓴 뒤 오프셋을 저장하기 전에 중단되면, 다음 PATCH는 기록된 오프셋부터 다시 쓴다
+func TestAppendUploadChunkTruncatesToRecordedOffset(t *testing.T) {
+	useUploadTempDir(t)
+	upload := tusUpload{ID: "interrupted", Length: 10, Offset: 5}
+
+	if err := os.WriteFile(uploadPath(upload.ID), []byte("helloXXXXXXX"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := appendUploadChunk(upload, strings.NewReader("world"))
+	if err != nil || written != 5 {
+		t.Fatalf("chunk = %d, %v", written, err)
+	}
+	if got := readUpload(t, upload.ID); got != "helloworld" {
+		t.Fatalf("contents = %q, want helloworld", got)
+	}
+}
+
+func TestAppendUploadChunkStopsAtLength(t *testing.T) {
+	useUploadTempDir(t)
+	upload := tusUpload{ID: "overflow", Length: 8, Offset: 0}
+
+	written, err := appendUploadChunk(upload, strings.NewReader("0123456789"))
+	if err != nil || written != 8 {
+		t.Fatalf("chunk = %d, %v", written, err)
+	}
+	if got := readUpload(t, upload.ID); got != "01234567" {
+		t.Fatalf("contents = %q", got)
+	}
+}
+
+type failingBody struct {
+	data []byte
+}
+
+func (b *failingBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+// 연결이 끊겨도 받은 바이트 수를 돌려주어 그 위치부터 이어 받을 수 있다
+func TestAppendUploadChunkReportsPartialWrite(t *testing.T) {
+	useUploadTempDir(t)
+	upload := tusUpload{ID: "partial", Length: 10}
+
+	written, err := appendUploadChunk(upload, &failingBody{data: []byte("hel")})
+	if err == nil || written != 3 {
+		t.Fatalf("chunk = %d, %v; want 3 bytes and an error", written, err)
+	}
+	upload.Offset += written
+
+	written, err = appendUploadChunk(upload, strings.NewReader("loworld"))
+	if err != nil || written != 7 {
+		t.Fatalf("resumed chunk = %d, %v", written, err)
+	}
+	if got := readUpload(t, upload.ID); got != "helloworld" {
+		t.Fatalf("contents = %q", got)
+	}
+}
+
+func TestUploadExpiry(t *testing.T) {
+	useUploadTempDir(t)
+	config.AppConfig.UploadExpiryHours = 6
+
+	got := time.Until(uploadExpiry())
+	if got < 6*time.Hour-time.Minute || got > 6*time.Hour {
+		t.Fatalf("expiry in %v, want 6h", got)
+	}
+}
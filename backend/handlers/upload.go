@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"messenger/config"
+	"messenger/media"
+	"messenger/models"
+	"messenger/scan"
+	"messenger/services"
+	"messenger/websocket"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 폼 필드 값은 작으므로 이 크기를 넘으면 거부한다
+const maxFormFieldSize = 64 << 10
+
+var errFilePartMissing = errors.New("file part missing")
+
+// openUploadPart 는 멀티파트 본문을 순서대로 읽어 field 이름의 파일 파트를 반환한다.
+// 파일 본문은 버퍼링하지 않고 스트리밍하므로, 함께 보내는 일반 필드는 파일보다 앞에 있어야 한다.
+func openUploadPart(c *gin.Context, field string) (*multipart.Part, map[string]string, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, values, errFilePartMissing
+		}
+		if err != nil {
+			return nil, values, err
+		}
+
+		if part.FormName() == field && part.FileName() != "" {
+			return part, values, nil
+		}
+
+		if part.FileName() == "" {
+			data, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize+1))
+			if err != nil {
+				return nil, values, err
+			}
+			if len(data) > maxFormFieldSize {
+				return nil, values, errors.New("form field too large")
+			}
+			values[part.FormName()] = string(data)
+		}
+		part.Close()
+	}
+}
+
+// 메시지 타입별 최대 업로드 크기 (바이트)
+func maxUploadSize(messageType string) int64 {
+	switch messageType {
+	case "video":
+		return int64(config.AppConfig.MaxVideoUploadMB) << 20
+	case "audio", "voice":
+		return int64(config.AppConfig.MaxAudioUploadMB) << 20
+	case "file":
+		return int64(config.AppConfig.MaxFileUploadMB) << 20
+	default:
+		return int64(config.AppConfig.MaxImageUploadMB) << 20
+	}
+}
+
+// 이어받기 업로드는 생성 시점에 타입을 모르므로 가장 큰 제한을 적용한다
+func maxAnyUploadSize() int64 {
+	var max int64
+	for _, messageType := range []string{"image", "video", "audio", "file"} {
+		if size := maxUploadSize(messageType); size > max {
+			max = size
+		}
+	}
+	return max
+}
+
+type sniffedUpload struct {
+	MimeType    string
+	MessageType string
+	Body        *media.LimitedReader
+}
+
+// sniffUpload 는 파일 바이트로 실제 타입을 감지해 허용 목록을 확인하고,
+// 타입별 크기 제한을 거는 리더를 돌려준다. 허용되지 않으면 media.ErrUnsupportedType.
+// voice는 바이트로 구분할 수 없으므로 클라이언트가 요청했을 때 오디오 파일에만 붙인다.
+func sniffUpload(part io.Reader, requestedType string) (sniffedUpload, error) {
+	mimeType, body, err := media.Sniff(part)
+	if err != nil {
+		return sniffedUpload{}, err
+	}
+
+	// 브라우저 녹음(MediaRecorder)은 오디오만 있어도 video/webm으로 감지된다
+	if requestedType == "voice" && mimeType == "video/webm" {
+		mimeType = "audio/webm"
+	}
+
+	messageType, err := media.MessageTypeFor(mimeType)
+	if err != nil {
+		return sniffedUpload{}, err
+	}
+	if requestedType == "voice" {
+		if messageType != "audio" {
+			return sniffedUpload{}, media.ErrUnsupportedType
+		}
+		messageType = "voice"
+	}
+
+	return sniffedUpload{
+		MimeType:    mimeType,
+		MessageType: messageType,
+		Body:        &media.LimitedReader{R: body, Limit: maxUploadSize(messageType)},
+	}, nil
+}
+
+// 업로드 검증 실패를 413/415 응답으로 바꾼다. 처리했으면 true.
+func abortUploadError(c *gin.Context, upload sniffedUpload, err error) bool {
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported file type"})
+	case upload.Body != nil && upload.Body.Exceeded:
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":    "File too large",
+			"max_size": upload.Body.Limit,
+		})
+	case errors.Is(err, media.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
+	default:
+		return false
+	}
+	return true
+}
+
+// 음성 메시지 파형은 0~255 진폭 값의 JSON 배열로 받는다
+const maxWaveformSamples = 256
+
+func parseWaveform(value string) ([]byte, error) {
+	var samples []int
+	if err := json.Unmarshal([]byte(value), &samples); err != nil {
+		return nil, err
+	}
+	if len(samples) > maxWaveformSamples {
+		return nil, errors.New("too many waveform samples")
+	}
+
+	waveform := make([]byte, len(samples))
+	for i, v := range samples {
+		if v < 0 || v > 255 {
+			return nil, errors.New("waveform sample out of range")
+		}
+		waveform[i] = byte(v)
+	}
+	return waveform, nil
+}
+
+func waveformSamples(waveform []byte) []int {
+	if len(waveform) == 0 {
+		return nil
+	}
+	samples := make([]int, len(waveform))
+	for i, v := range waveform {
+		samples[i] = int(v)
+	}
+	return samples
+}
+
+func fileExtension(filename string) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(filename), "."))
+}
+
+// checkBlobScan 은 메시지로 보내기 전에 파일을 검사한다.
+// 감염된 파일은 격리된 채로 남기고, 보낸 사람에게만 알린 뒤 422로 거부한다.
+func checkBlobScan(c *gin.Context, userID, roomID int, filename string, blob *services.Blob) bool {
+	err := services.ScanBlob(c.Request.Context(), blob)
+	switch {
+	case errors.Is(err, scan.ErrScanLimit):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large to scan"})
+		return false
+	case err != nil:
+		log.Printf("Failed to scan blob %s: %v", blob.SHA256, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File could not be scanned"})
+		return false
+	}
+
+	if blob.ScanStatus != "infected" {
+		return true
+	}
+
+	payload := gin.H{
+		"filename":  filename,
+		"reason":    "malware",
+		"signature": blob.Signature,
+	}
+	if roomID != 0 {
+		payload["room_id"] = roomID
+	}
+	websocket.BroadcastToUser(userID, models.WebSocketMessage{
+		Type:    "upload_rejected",
+		Payload: payload,
+	})
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":     "File rejected by malware scan",
+		"signature": blob.Signature,
+	})
+	return false
+}
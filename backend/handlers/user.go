@@ -1,13 +1,24 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/base64"
+	"fmt"
 	"io"
 	"messenger/config"
+	"messenger/media"
 	"messenger/middleware"
 	"messenger/models"
+	"messenger/services"
+	"messenger/storage"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,14 +28,19 @@ func GetProfile(c *gin.Context) {
 
 	var user models.User
 	var profileImage []byte
+	var profileImageKey sql.NullString
 	var profileImageMime sql.NullString
+	var statusMessage sql.NullString
+	var hasBackground bool
 
 	err := config.DB.QueryRow(`
-		SELECT id, username, phone, name, profile_image, profile_image_mime, created_at, updated_at
+		SELECT id, username, phone, name, profile_image, profile_image_key, profile_image_mime,
+			status_message, background_image_key IS NOT NULL, created_at, updated_at
 		FROM users WHERE id = $1
 	`, userID).Scan(
 		&user.ID, &user.Username, &user.Phone, &user.Name,
-		&profileImage, &profileImageMime, &user.CreatedAt, &user.UpdatedAt,
+		&profileImage, &profileImageKey, &profileImageMime,
+		&statusMessage, &hasBackground, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -33,12 +49,21 @@ func GetProfile(c *gin.Context) {
 	}
 
 	response := gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"phone":      user.Phone,
-		"name":       user.Name,
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
+		"id":                   user.ID,
+		"username":             user.Username,
+		"phone":                user.Phone,
+		"name":                 user.Name,
+		"status_message":       statusMessage.String,
+		"has_background_image": hasBackground,
+		"created_at":           user.CreatedAt,
+		"updated_at":           user.UpdatedAt,
+	}
+
+	if profileImageKey.Valid {
+		if object, _, err := storage.Default.Open(c.Request.Context(), profileImageKey.String); err == nil {
+			profileImage, _ = io.ReadAll(object)
+			object.Close()
+		}
 	}
 
 	if profileImage != nil && profileImageMime.Valid {
@@ -58,73 +83,250 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// nil인 항목은 기존 값을 유지한다
+	var fields []string
+	var name, statusMessage sql.NullString
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" || utf8.RuneCountInString(trimmed) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be 1-100 characters"})
+			return
+		}
+		name = sql.NullString{String: trimmed, Valid: true}
+		fields = append(fields, "name")
+	}
+	if req.StatusMessage != nil {
+		trimmed := strings.TrimSpace(*req.StatusMessage)
+		if utf8.RuneCountInString(trimmed) > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status message is too long"})
+			return
+		}
+		statusMessage = sql.NullString{String: trimmed, Valid: trimmed != ""}
+		fields = append(fields, "status_message")
+	}
+
 	_, err := config.DB.Exec(`
-		UPDATE users SET name = $1, updated_at = NOW() WHERE id = $2
-	`, req.Name, userID)
+		UPDATE users SET
+			name = COALESCE($1, name),
+			status_message = CASE WHEN $2 THEN $3 ELSE status_message END,
+			updated_at = NOW()
+		WHERE id = $4
+	`, name, req.StatusMessage != nil, statusMessage, userID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
 
+	if len(fields) > 0 {
+		notifyProfileUpdated(userID, fields...)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
 
 func UpdateProfileImage(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	file, header, err := c.Request.FormFile("image")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Image file required"})
+	blob, info, ok := storeImageUpload(c, userID)
+	if !ok {
 		return
 	}
-	defer file.Close()
 
-	imageData, err := io.ReadAll(file)
+	var oldKey, oldSHA, oldMime sql.NullString
+	var oldWidth, oldHeight sql.NullInt64
+	config.DB.QueryRow(`
+		SELECT profile_image_key, profile_image_sha256, profile_image_mime, profile_image_width, profile_image_height
+		FROM users WHERE id = $1
+	`, userID).Scan(&oldKey, &oldSHA, &oldMime, &oldWidth, &oldHeight)
+
+	tx, err := config.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile image"})
 		return
 	}
-
-	mimeType := header.Header.Get("Content-Type")
-	if mimeType == "" {
-		mimeType = "image/jpeg"
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET profile_image = NULL, profile_image_key = $1, profile_image_sha256 = $2, profile_image_mime = $3,
+			profile_image_width = $4, profile_image_height = $5, updated_at = NOW()
+		WHERE id = $6
+	`, blob.StorageKey, blob.SHA256, blob.MimeType, info.Width, info.Height, userID)
+
+	// 이전 사진은 지난 프로필로 남긴다. 기록이 blob을 참조하므로 그대로 보존된다.
+	if err == nil && oldSHA.Valid && oldSHA.String != blob.SHA256 {
+		_, err = tx.Exec(`
+			INSERT INTO profile_image_history (user_id, blob_sha256, storage_key, mime_type, width, height)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, userID, oldSHA.String, oldKey.String, oldMime.String, oldWidth, oldHeight)
+		if err == nil {
+			_, err = tx.Exec(`
+				DELETE FROM profile_image_history
+				WHERE user_id = $1 AND id NOT IN (
+					SELECT id FROM profile_image_history WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2
+				)
+			`, userID, maxProfileHistory)
+		}
 	}
 
-	_, err = config.DB.Exec(`
-		UPDATE users SET profile_image = $1, profile_image_mime = $2, updated_at = NOW()
-		WHERE id = $3
-	`, imageData, mimeType, userID)
-
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile image"})
 		return
 	}
 
+	// blob으로 저장된 이전 이미지는 트리거가 참조 수를 줄인다
+	if oldKey.Valid && !oldSHA.Valid {
+		media.DeleteWithThumbnails(context.Background(), storage.Default, oldKey.String)
+	}
+
+	notifyProfileUpdated(userID, "profile_image")
+
 	c.JSON(http.StatusOK, gin.H{"message": "Profile image updated successfully"})
 }
 
+// storeImageUpload 는 multipart "image" 파트를 이미지인지 확인하고 메타데이터를 지운 뒤 blob으로 저장한다.
+// 실패하면 응답을 쓰고 false를 돌려준다.
+func storeImageUpload(c *gin.Context, userID int) (services.Blob, media.ImageInfo, bool) {
+	part, fields, err := openUploadPart(c, "image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image file required"})
+		return services.Blob{}, media.ImageInfo{}, false
+	}
+	defer part.Close()
+
+	sniffed, err := sniffUpload(part, "")
+	if err == nil && sniffed.MessageType != "image" {
+		err = media.ErrUnsupportedType
+	}
+	if err != nil {
+		if !abortUploadError(c, sniffed, err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		}
+		return services.Blob{}, media.ImageInfo{}, false
+	}
+	mimeType := sniffed.MimeType
+
+	var content io.Reader = sniffed.Body
+	if fields["original"] != "true" {
+		stripped := media.StripMetadata(mimeType, sniffed.Body)
+		defer stripped.Close()
+		content = stripped
+	}
+
+	key := storage.NewKey("profiles")
+	hasher := services.NewBlobHasher()
+	size, err := storage.Default.Put(c.Request.Context(), key, hasher.Reader(content), mimeType)
+	if err != nil {
+		if !abortUploadError(c, sniffed, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+		}
+		return services.Blob{}, media.ImageInfo{}, false
+	}
+
+	blob, existed, err := services.ClaimBlob(c.Request.Context(), hasher.Sum(), key, size, mimeType)
+	if err != nil {
+		storage.Default.Delete(context.Background(), key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return services.Blob{}, media.ImageInfo{}, false
+	}
+
+	// 이 아래에서 실패하면 참조되지 않은 blob은 정리 작업이 지운다
+	if !checkBlobScan(c, userID, 0, part.FileName(), &blob) {
+		return services.Blob{}, media.ImageInfo{}, false
+	}
+
+	info := blob.Image
+	if !existed || info == nil {
+		processed, err := media.ProcessImage(c.Request.Context(), storage.Default, blob.StorageKey)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
+			return services.Blob{}, media.ImageInfo{}, false
+		}
+		services.SetBlobImageInfo(blob.SHA256, processed)
+		info = &processed
+	}
+
+	return blob, *info, true
+}
+
 func GetProfileImage(c *gin.Context) {
-	userID := c.Param("id")
+	viewerID := middleware.GetUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if !services.CanView(viewerID, userID, services.GetUserSettings(userID).ProfileImageVisibility) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile image not found"})
+		return
+	}
 
 	var imageData []byte
+	var imageKey sql.NullString
 	var mimeType sql.NullString
+	var width, height sql.NullInt64
+	var updatedAt time.Time
 
-	err := config.DB.QueryRow(`
-		SELECT profile_image, profile_image_mime FROM users WHERE id = $1
-	`, userID).Scan(&imageData, &mimeType)
+	err = config.DB.QueryRow(`
+		SELECT profile_image, profile_image_key, profile_image_mime, profile_image_width, profile_image_height, updated_at
+		FROM users WHERE id = $1
+	`, userID).Scan(&imageData, &imageKey, &mimeType, &width, &height, &updatedAt)
 
-	if err != nil || imageData == nil {
+	if err != nil || (imageData == nil && !imageKey.Valid) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Profile image not found"})
 		return
 	}
 
-	contentType := "image/jpeg"
-	if mimeType.Valid {
-		contentType = mimeType.String
+	if !imageKey.Valid {
+		contentType := "image/jpeg"
+		if mimeType.Valid {
+			contentType = media.SafeContentType(mimeType.String)
+		}
+		serveMedia(c, mediaContent{
+			Content:      bytes.NewReader(imageData),
+			ContentType:  contentType,
+			ModTime:      updatedAt,
+			ETag:         fmt.Sprintf("W/\"%d\"", updatedAt.Unix()),
+			CacheControl: "private, no-cache",
+		})
+		return
+	}
+
+	serveStoredImage(c, imageKey.String, mimeType.String, width, height, updatedAt)
+}
+
+// serveStoredImage 는 스토리지에 있는 이미지(또는 ?size= 썸네일)를 내려준다.
+// 같은 URL에서 이미지가 바뀔 수 있으므로 매번 재검증하게 한다.
+func serveStoredImage(c *gin.Context, key, mimeType string, width, height sql.NullInt64, modTime time.Time) {
+	content := mediaContent{
+		ContentType:  media.SafeContentType(mimeType),
+		ModTime:      modTime,
+		ETag:         "\"" + path.Base(key) + "\"",
+		CacheControl: "private, no-cache",
+	}
+
+	if size := c.Query("size"); size != "" && width.Valid && height.Valid {
+		if thumbKey, ok := media.ThumbnailFor(key, size, int(width.Int64), int(height.Int64)); ok {
+			key = thumbKey
+			content.ContentType = "image/jpeg"
+			content.ETag = "\"" + path.Base(thumbKey) + "\""
+		}
+	}
+
+	object, _, err := storage.Default.Open(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
 	}
+	defer object.Close()
 
-	c.Data(http.StatusOK, contentType, imageData)
+	content.Content = object
+	serveMedia(c, content)
 }
 
 func SearchUser(c *gin.Context) {
@@ -136,34 +338,92 @@ func SearchUser(c *gin.Context) {
 
 	currentUserID := middleware.GetUserID(c)
 
-	rows, err := config.DB.Query(`
-		SELECT id, username, name FROM users
-		WHERE (username = $1 OR phone = $1) AND id != $2
-		LIMIT 10
-	`, req.Query, currentUserID)
-
+	users, err := services.FindUsers(currentUserID, req.Query, 10)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
 		return
 	}
-	defer rows.Close()
 
-	var users []gin.H
-	for rows.Next() {
-		var id int
-		var username, name string
-		if err := rows.Scan(&id, &username, &name); err == nil {
-			users = append(users, gin.H{
-				"id":       id,
-				"username": username,
-				"name":     name,
-			})
-		}
+	c.JSON(http.StatusOK, users)
+}
+
+func GetNotificationSettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	c.JSON(http.StatusOK, services.GetNotificationSettings(userID))
+}
+
+func UpdateNotificationSettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	settings := services.GetNotificationSettings(userID)
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if users == nil {
-		users = []gin.H{}
+	if err := services.ValidateNotificationSettings(settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	if err := services.SaveNotificationSettings(userID, settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func GetUserSettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	c.JSON(http.StatusOK, services.GetUserSettings(userID))
+}
+
+func UpdateUserSettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	settings := services.GetUserSettings(userID)
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ValidateUserSettings(settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.SaveUserSettings(userID, settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func GetPrivacySettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	c.JSON(http.StatusOK, services.GetPrivacySettings(userID))
+}
+
+func UpdatePrivacySettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	settings := services.GetPrivacySettings(userID)
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ValidatePrivacySettings(settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.SavePrivacySettings(userID, settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
 }
@@ -5,7 +5,12 @@ import (
 	"messenger/config"
 	"messenger/handlers"
 	"messenger/middleware"
+	"messenger/push"
+	"messenger/scan"
+	"messenger/services"
+	"messenger/storage"
 	"messenger/websocket"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,13 +21,32 @@ func main() {
 	}
 	defer config.DB.Close()
 
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	if err := scan.Init(); err != nil {
+		log.Fatalf("Failed to initialize malware scanner: %v", err)
+	}
+
+	push.Init()
+	handlers.StartUploadCleanup()
+	handlers.StartAccountPurge()
+	services.StartBlobCleanup()
+
 	r := gin.Default()
 
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, HEAD, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Range, If-None-Match, If-Modified-Since, "+
+			"Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range, Content-Disposition, Accept-Ranges, ETag, "+
+			"Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Expires, Upload-Message-Id")
 		if c.Request.Method == "OPTIONS" {
+			if strings.HasPrefix(c.Request.URL.Path, "/api/uploads") {
+				handlers.TusOptions(c)
+			}
 			c.AbortWithStatus(204)
 			return
 		}
@@ -37,6 +61,9 @@ func main() {
 			auth.POST("/verify-code", handlers.VerifyCode)
 			auth.POST("/register", handlers.Register)
 			auth.POST("/login", handlers.Login)
+			auth.POST("/password-reset/send-code", handlers.SendPasswordResetCode)
+			auth.POST("/password-reset/verify", handlers.VerifyPasswordReset)
+			auth.POST("/password-reset/complete", handlers.CompletePasswordReset)
 		}
 
 		users := api.Group("/users")
@@ -44,9 +71,33 @@ func main() {
 		{
 			users.GET("/me", handlers.GetProfile)
 			users.PUT("/me", handlers.UpdateProfile)
+			users.DELETE("/me", handlers.DeleteAccount)
+			users.GET("/me/export", handlers.ExportUserData)
 			users.PUT("/me/profile-image", handlers.UpdateProfileImage)
+			users.PUT("/me/background-image", handlers.UpdateBackgroundImage)
+			users.DELETE("/me/background-image", handlers.DeleteBackgroundImage)
+			users.DELETE("/me/profile-history/:historyId", handlers.DeleteProfileHistory)
+			users.GET("/me/notification-settings", handlers.GetNotificationSettings)
+			users.PUT("/me/notification-settings", handlers.UpdateNotificationSettings)
+			users.POST("/me/push-tokens", handlers.RegisterPushToken)
+			users.DELETE("/me/push-tokens", handlers.UnregisterPushToken)
+			users.GET("/me/settings", handlers.GetUserSettings)
+			users.PUT("/me/settings", handlers.UpdateUserSettings)
+			users.GET("/me/privacy", handlers.GetPrivacySettings)
+			users.PUT("/me/privacy", handlers.UpdatePrivacySettings)
+			users.PUT("/me/username", handlers.ChangeUsername)
+			users.PUT("/me/password", handlers.ChangePassword)
+			users.POST("/me/phone/send-code", handlers.SendPhoneChangeCode)
+			users.PUT("/me/phone", handlers.VerifyPhoneChange)
+			users.GET("/me/blocks", handlers.GetBlockedUsers)
+			users.POST("/me/blocks", handlers.BlockUser)
+			users.DELETE("/me/blocks/:userId", handlers.UnblockUser)
 			users.GET("/search", handlers.SearchUser)
 			users.GET("/:id/profile-image", handlers.GetProfileImage)
+			users.GET("/:id/profile", handlers.GetUserProfile)
+			users.GET("/:id/presence", handlers.GetPresence)
+			users.GET("/:id/background-image", handlers.GetBackgroundImage)
+			users.GET("/:id/profile-history/:historyId", handlers.GetProfileHistoryImage)
 		}
 
 		friends := api.Group("/friends")
@@ -55,6 +106,21 @@ func main() {
 			friends.GET("", handlers.GetFriends)
 			friends.POST("", handlers.AddFriend)
 			friends.DELETE("/:id", handlers.DeleteFriend)
+			friends.PUT("/:id/preferences", handlers.UpdateFriendPreferences)
+			friends.GET("/requests/incoming", handlers.GetIncomingFriendRequests)
+			friends.GET("/requests/outgoing", handlers.GetOutgoingFriendRequests)
+			friends.POST("/requests/:id/accept", handlers.AcceptFriendRequest)
+			friends.POST("/requests/:id/decline", handlers.DeclineFriendRequest)
+			friends.DELETE("/requests/:id", handlers.CancelFriendRequest)
+			friends.GET("/recommendations", handlers.GetFriendRecommendations)
+			friends.POST("/recommendations/:userId/dismiss", handlers.DismissFriendRecommendation)
+		}
+
+		contacts := api.Group("/contacts")
+		contacts.Use(middleware.AuthRequired())
+		{
+			contacts.GET("", handlers.GetContactMatches)
+			contacts.POST("/sync", handlers.SyncContacts)
 		}
 
 		rooms := api.Group("/rooms")
@@ -62,19 +128,53 @@ func main() {
 		{
 			rooms.GET("", handlers.GetRooms)
 			rooms.POST("", handlers.CreateRoom)
+			rooms.GET("/:id", handlers.GetRoom)
 			rooms.DELETE("/:id/leave", handlers.LeaveRoom)
 			rooms.GET("/:id/members", handlers.GetRoomMembers)
+			rooms.PUT("/:id/members/:userId/role", handlers.UpdateMemberRole)
 			rooms.GET("/:id/messages", handlers.GetMessages)
 			rooms.POST("/:id/messages", handlers.SendMessage)
 			rooms.POST("/:id/files", handlers.SendFile)
+			rooms.GET("/:id/media", handlers.GetRoomMedia)
 			rooms.POST("/:id/mute", handlers.ToggleMute)
+			rooms.PUT("/:id/preferences", handlers.UpdateRoomPreferences)
 			rooms.POST("/:id/read", handlers.MarkRead)
+			rooms.POST("/:id/pins", handlers.PinMessage)
+			rooms.DELETE("/:id/pins/:messageId", handlers.UnpinMessage)
+			rooms.PUT("/:id/announcement", handlers.SetAnnouncement)
+			rooms.DELETE("/:id/announcement", handlers.DeleteAnnouncement)
+			rooms.POST("/:id/announcement/dismiss", handlers.DismissAnnouncement)
+		}
+
+		folders := api.Group("/folders")
+		folders.Use(middleware.AuthRequired())
+		{
+			folders.GET("", handlers.GetFolders)
+			folders.POST("", handlers.CreateFolder)
+			folders.PUT("/:id", handlers.UpdateFolder)
+			folders.DELETE("/:id", handlers.DeleteFolder)
+		}
+
+		uploads := api.Group("/uploads")
+		uploads.Use(middleware.AuthRequired())
+		{
+			uploads.POST("", handlers.CreateUpload)
+			uploads.HEAD("/:id", handlers.GetUploadOffset)
+			uploads.PATCH("/:id", handlers.PatchUpload)
+			uploads.DELETE("/:id", handlers.DeleteUpload)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthRequired(), middleware.AdminRequired())
+		{
+			admin.GET("/storage", handlers.GetStorageReport)
+			admin.GET("/quarantine", handlers.GetQuarantinedFiles)
 		}
 
 		files := api.Group("/files")
-		files.Use(middleware.AuthRequired())
 		{
-			files.GET("/:id", handlers.GetFile)
+			files.GET("/:id", middleware.FileAccess(), handlers.GetFile)
+			files.GET("/:id/url", middleware.AuthRequired(), handlers.GetFileURL)
 		}
 	}
 
@@ -0,0 +1,72 @@
+package media
+
+import (
+	"image"
+	"testing"
+)
+
+func blurhashImage(w, h int, pixel func(x, y int) (uint8, uint8, uint8)) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2] = pixel(x, y)
+			img.Pix[i+3] = 255
+		}
+	}
+	return img
+}
+
+// 기대값은 참조 구현(woltapp/blurhash의 TypeScript 인코더)으로 같은 픽셀을 인코딩한 결과다
+func TestBlurhashKnownAnswers(t *testing.T) {
+	white := blurhashImage(4, 4, func(x, y int) (uint8, uint8, uint8) { return 255, 255, 255 })
+	gradient := blurhashImage(8, 6, func(x, y int) (uint8, uint8, uint8) {
+		return uint8(x * 32), uint8(y * 48), uint8(255 - x*16 - y*16)
+	})
+	checker := blurhashImage(6, 6, func(x, y int) (uint8, uint8, uint8) {
+		if (x/2+y/3)%2 == 0 {
+			return 255, 255, 255
+		}
+		return 20, 60, 200
+	})
+
+	tests := []struct {
+		name        string
+		img         *image.RGBA
+		xComponents int
+		yComponents int
+		want        string
+	}{
+		{"white 4x3", white, 4, 3, "L~TSUA~qfQ~q~q%MfQ%MfQfQfQfQ"},
+		{"gradient 4x3", gradient, 4, 3, "LuF?YB7jb2xwu$RrfTnUevfAfRf9"},
+		{"gradient dc only", gradient, 1, 1, "00F?YB"},
+		{"gradient 9x9", gradient, 9, 9, "|uF?YB7jb2xwJp%3Jp%3Jpu$RrfTnUWrnUWrnUWrevfAfRf9fRf9fRf9fRyDS%fSofWrofWrofWrd~e?fRe?fRe?fRe?fRyDS%fSofWrofWrofWrd~e?fRe?fRe?fRe?fRyDS%fSofWrofWrofWrd~e?fRe?fRe?fRe?fR"},
+		{"checker 3x2", checker, 3, 2, "BzLqn=-?fQ~qoz_0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Blurhash(tt.img, tt.xComponents, tt.yComponents); got != tt.want {
+				t.Fatalf("Blurhash = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode83(t *testing.T) {
+	tests := []struct {
+		value, length int
+		want          string
+	}{
+		{0, 1, "0"},
+		{82, 1, "~"},
+		{83, 2, "10"},
+		{0xFFFFFF, 4, "TSUA"},
+		{3429, 2, "fQ"},
+	}
+	for _, tt := range tests {
+		if got := encode83(tt.value, tt.length); got != tt.want {
+			t.Errorf("encode83(%d, %d) = %s, want %s", tt.value, tt.length, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// meta 박스는 항목 정보만 담으므로 이보다 크면 비정상으로 본다
+const maxHEIFMetaSize = 16 << 20
+
+// iloc 의 크기 필드가 0이면 extent 가 바이트를 쓰지 않으므로 개수로 제한한다
+const maxHEIFExtents = 1 << 16
+
+// Exif 위치(iloc)를 알기 전에 이미지 데이터가 나오면 스트리밍 중에 지울 수 없다
+var errHEIFLayout = errors.New("media: HEIF meta box must precede mdat")
+
+type byteRange struct {
+	start, end int64
+}
+
+// stripHEIF 는 ISO BMFF 박스 구조와 오프셋을 그대로 두고 Exif/XMP 항목의 바이트만 0으로 덮는다.
+func stripHEIF(w io.Writer, r *bufio.Reader) error {
+	var pos int64
+	var ranges []byteRange
+	sawMeta := false
+
+	for {
+		header := make([]byte, 8)
+		n, err := io.ReadFull(r, header)
+		if err == io.EOF && n == 0 {
+			if !sawMeta {
+				return ErrInvalidImage
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		if size == 1 {
+			large := make([]byte, 8)
+			if _, err := io.ReadFull(r, large); err != nil {
+				return err
+			}
+			header = append(header, large...)
+			size = int64(binary.BigEndian.Uint64(large))
+		}
+		headerLen := int64(len(header))
+		if size != 0 && size < headerLen {
+			return ErrInvalidImage
+		}
+
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		payloadStart := pos + headerLen
+
+		switch {
+		case boxType == "meta":
+			if size == 0 || size-headerLen > maxHEIFMetaSize {
+				return ErrInvalidImage
+			}
+			payload := make([]byte, size-headerLen)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return err
+			}
+			if ranges, err = heifMetadataRanges(payload, payloadStart); err != nil {
+				return err
+			}
+			sawMeta = true
+			zeroRanges(payload, payloadStart, ranges)
+			if _, err := w.Write(payload); err != nil {
+				return err
+			}
+
+		case boxType == "mdat" && !sawMeta:
+			return errHEIFLayout
+
+		default:
+			remaining := int64(-1)
+			if size != 0 {
+				remaining = size - headerLen
+			}
+			if err := copyZeroed(w, r, payloadStart, remaining, ranges); err != nil {
+				return err
+			}
+			if size == 0 {
+				return nil
+			}
+		}
+
+		pos += size
+	}
+}
+
+// copyZeroed 는 n바이트(-1이면 끝까지)를 복사하면서 ranges에 걸친 바이트를 0으로 바꾼다.
+func copyZeroed(w io.Writer, r io.Reader, pos, n int64, ranges []byteRange) error {
+	buf := make([]byte, 32<<10)
+	for n != 0 {
+		chunk := buf
+		if n > 0 && n < int64(len(chunk)) {
+			chunk = chunk[:n]
+		}
+		read, err := r.Read(chunk)
+		if read > 0 {
+			zeroRanges(chunk[:read], pos, ranges)
+			if _, werr := w.Write(chunk[:read]); werr != nil {
+				return werr
+			}
+			pos += int64(read)
+			if n > 0 {
+				n -= int64(read)
+			}
+		}
+		if err == io.EOF {
+			if n > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zeroRanges 는 파일 위치 offset에서 시작하는 data 중 ranges와 겹치는 부분을 0으로 만든다.
+func zeroRanges(data []byte, offset int64, ranges []byteRange) {
+	end := offset + int64(len(data))
+	for _, rg := range ranges {
+		start, stop := max(rg.start, offset), min(rg.end, end)
+		for i := start; i < stop; i++ {
+			data[i-offset] = 0
+		}
+	}
+}
+
+// boxReader 는 박스 내용을 읽는 커서다. 범위를 벗어나면 err를 세우고 0을 반환한다.
+type boxReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (b *boxReader) bytes(n int) []byte {
+	if b.err != nil || n < 0 || b.off+n > len(b.data) {
+		b.err = ErrInvalidImage
+		return make([]byte, max(n, 0))
+	}
+	v := b.data[b.off : b.off+n]
+	b.off += n
+	return v
+}
+
+func (b *boxReader) uint(n int) int64 {
+	var v int64
+	for _, c := range b.bytes(n) {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// heifChildren 은 data 안의 하위 박스를 (타입, 내용, 내용 시작 위치)로 나눈다.
+func heifChildren(data []byte, each func(boxType string, payload []byte, payloadOff int) error) error {
+	off := 0
+	for off+8 <= len(data) {
+		// off+size 는 넘칠 수 있으므로 남은 길이와 비교한다
+		remaining := uint64(len(data) - off)
+		size := uint64(binary.BigEndian.Uint32(data[off:]))
+		boxType := string(data[off+4 : off+8])
+		headerLen := uint64(8)
+		if size == 1 {
+			if remaining < 16 {
+				return ErrInvalidImage
+			}
+			size = binary.BigEndian.Uint64(data[off+8:])
+			headerLen = 16
+		} else if size == 0 {
+			size = remaining
+		}
+		if size < headerLen || size > remaining {
+			return ErrInvalidImage
+		}
+		end := off + int(size)
+		if err := each(boxType, data[off+int(headerLen):end], off+int(headerLen)); err != nil {
+			return err
+		}
+		off = end
+	}
+	return nil
+}
+
+// heifMetadataRanges 는 meta 박스 내용(payload, 파일 위치 payloadStart)에서
+// Exif 항목과 XMP(mime: application/rdf+xml) 항목이 차지하는 파일 범위를 구한다.
+func heifMetadataRanges(payload []byte, payloadStart int64) ([]byteRange, error) {
+	if len(payload) < 4 {
+		return nil, ErrInvalidImage
+	}
+
+	targets := map[int64]bool{}
+	type extent struct{ offset, length int64 }
+	type location struct {
+		method  int64
+		extents []extent
+	}
+	locations := map[int64]location{}
+	idatStart := int64(-1)
+
+	// meta는 FullBox이므로 version/flags 4바이트 뒤부터 하위 박스다
+	err := heifChildren(payload[4:], func(boxType string, box []byte, boxOff int) error {
+		switch boxType {
+		case "idat":
+			idatStart = payloadStart + 4 + int64(boxOff)
+
+		case "iinf":
+			br := &boxReader{data: box}
+			version := br.uint(1)
+			br.bytes(3)
+			if version == 0 {
+				br.uint(2)
+			} else {
+				br.uint(4)
+			}
+			if br.err != nil {
+				return br.err
+			}
+			return heifChildren(box[br.off:], func(childType string, infe []byte, _ int) error {
+				if childType != "infe" {
+					return nil
+				}
+				ir := &boxReader{data: infe}
+				infeVersion := ir.uint(1)
+				ir.bytes(3)
+				if infeVersion < 2 {
+					return nil
+				}
+				var itemID int64
+				if infeVersion == 2 {
+					itemID = ir.uint(2)
+				} else {
+					itemID = ir.uint(4)
+				}
+				ir.uint(2)
+				itemType := string(ir.bytes(4))
+				if ir.err != nil {
+					return ir.err
+				}
+				if itemType == "Exif" {
+					targets[itemID] = true
+				}
+				if itemType == "mime" {
+					// item_name(널 종료) 다음에 content_type이 온다
+					rest := infe[ir.off:]
+					if i := bytes.IndexByte(rest, 0); i >= 0 {
+						rest = rest[i+1:]
+					}
+					if bytes.HasPrefix(rest, []byte("application/rdf+xml")) {
+						targets[itemID] = true
+					}
+				}
+				return nil
+			})
+
+		case "iloc":
+			br := &boxReader{data: box}
+			version := br.uint(1)
+			br.bytes(3)
+			sizes := br.uint(1)
+			offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+			sizes = br.uint(1)
+			baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0F)
+
+			var itemCount int64
+			if version < 2 {
+				itemCount = br.uint(2)
+			} else {
+				itemCount = br.uint(4)
+			}
+
+			// ISO/IEC 14496-12: 각 크기 필드는 0, 4, 8 중 하나다
+			for _, size := range []int{offsetSize, lengthSize, baseOffsetSize, indexSize} {
+				if size != 0 && size != 4 && size != 8 {
+					return ErrInvalidImage
+				}
+			}
+
+			totalExtents := int64(0)
+			for i := int64(0); i < itemCount && br.err == nil; i++ {
+				var itemID int64
+				if version < 2 {
+					itemID = br.uint(2)
+				} else {
+					itemID = br.uint(4)
+				}
+				var loc location
+				if version == 1 || version == 2 {
+					loc.method = br.uint(2) & 0x0F
+				}
+				br.uint(2) // data_reference_index
+				baseOffset := br.uint(baseOffsetSize)
+				extentCount := br.uint(2)
+				if totalExtents += extentCount; totalExtents > maxHEIFExtents {
+					return ErrInvalidImage
+				}
+				for j := int64(0); j < extentCount && br.err == nil; j++ {
+					if (version == 1 || version == 2) && indexSize > 0 {
+						br.uint(indexSize)
+					}
+					offset := br.uint(offsetSize)
+					length := br.uint(lengthSize)
+					if baseOffset < 0 || offset < 0 || offset > math.MaxInt64-baseOffset {
+						return ErrInvalidImage
+					}
+					loc.extents = append(loc.extents, extent{offset: baseOffset + offset, length: length})
+				}
+				locations[itemID] = loc
+			}
+			return br.err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []byteRange
+	for itemID := range targets {
+		loc, ok := locations[itemID]
+		if !ok {
+			continue
+		}
+		for _, e := range loc.extents {
+			// 8바이트 필드는 음수가 될 수 있고, 더하면 넘칠 수 있다
+			if e.offset < 0 || e.length <= 0 {
+				return nil, ErrInvalidImage
+			}
+			start := e.offset
+			switch loc.method {
+			case 0: // 파일 오프셋
+			case 1: // idat 박스 내부 오프셋
+				if idatStart < 0 {
+					return nil, ErrInvalidImage
+				}
+				start += idatStart
+			default:
+				return nil, ErrInvalidImage
+			}
+			if start < 0 || start > math.MaxInt64-e.length {
+				return nil, ErrInvalidImage
+			}
+			ranges = append(ranges, byteRange{start: start, end: start + e.length})
+		}
+	}
+	return ranges, nil
+}
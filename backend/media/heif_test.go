@@ -0,0 +1,244 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func heifBox(boxType string, payload ...[]byte) []byte {
+	box := make([]byte, 8)
+	copy(box[4:], boxType)
+	for _, p := range payload {
+		box = append(box, p...)
+	}
+	binary.BigEndian.PutUint32(box, uint32(len(box)))
+	return box
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+var fullBoxV0 = []byte{0, 0, 0, 0}
+
+// infe 버전 2: item_ID, protection_index, item_type, item_name
+func heifInfe(itemID uint16, itemType string, extra ...byte) []byte {
+	payload := append([]byte{2, 0, 0, 0}, u16(itemID)...)
+	payload = append(payload, u16(0)...)
+	payload = append(payload, itemType...)
+	payload = append(payload, 0)
+	return heifBox("infe", payload, extra)
+}
+
+func heifIinf(entries ...[]byte) []byte {
+	return heifBox("iinf", append(append([]byte{}, fullBoxV0...), u16(uint16(len(entries)))...), bytes.Join(entries, nil))
+}
+
+// heifIlocV0 은 offset/length 4바이트, base_offset 없음인 iloc 을 만든다. items 는 (item_ID, offset, length) 목록이다.
+func heifIlocV0(items ...[3]uint32) []byte {
+	payload := append(append([]byte{}, fullBoxV0...), 0x44, 0x00)
+	payload = append(payload, u16(uint16(len(items)))...)
+	for _, item := range items {
+		payload = append(payload, u16(uint16(item[0]))...)
+		payload = append(payload, u16(0)...) // data_reference_index
+		payload = append(payload, u16(1)...) // extent_count
+		payload = append(payload, u32(item[1])...)
+		payload = append(payload, u32(item[2])...)
+	}
+	return heifBox("iloc", payload)
+}
+
+func heifMeta(children ...[]byte) []byte {
+	return heifBox("meta", fullBoxV0, bytes.Join(children, nil))
+}
+
+// testHEIF 는 ftyp, meta, mdat 순서의 파일을 만든다. mdat 은 Exif 항목 다음에 이미지 데이터가 온다.
+func testHEIF() (data []byte, exifStart, exifEnd int) {
+	exif := append([]byte("\x00\x00\x00\x06Exif\x00\x00"), exifTIFF(6)...)
+	pixels := []byte("HEVC-IMAGE-DATA")
+	ftyp := heifBox("ftyp", []byte("heic"), u32(0), []byte("mif1heic"))
+
+	build := func(offset uint32) []byte {
+		meta := heifMeta(
+			heifIinf(heifInfe(1, "hvc1"), heifInfe(2, "Exif")),
+			heifIlocV0([3]uint32{1, offset + uint32(len(exif)), uint32(len(pixels))}, [3]uint32{2, offset, uint32(len(exif))}),
+		)
+		return bytes.Join([][]byte{ftyp, meta, heifBox("mdat", exif, pixels)}, nil)
+	}
+
+	// iloc 크기는 오프셋 값과 관계없으므로 한 번 만들어 위치를 구한다
+	exifStart = len(build(0)) - len(pixels) - len(exif)
+	return build(uint32(exifStart)), exifStart, exifStart + len(exif)
+}
+
+func TestStripHEIF(t *testing.T) {
+	data, exifStart, exifEnd := testHEIF()
+
+	out, err := strip("image/heic", data)
+	if err != nil {
+		t.Fatalf("strip: %v", err)
+	}
+	if len(out) != len(data) {
+		t.Fatalf("len = %d, want %d (offsets must not move)", len(out), len(data))
+	}
+	if !bytes.Equal(out[:exifStart], data[:exifStart]) || !bytes.Equal(out[exifEnd:], data[exifEnd:]) {
+		t.Fatal("bytes outside the Exif item changed")
+	}
+	if !bytes.Equal(out[exifStart:exifEnd], make([]byte, exifEnd-exifStart)) {
+		t.Fatal("Exif item not zeroed")
+	}
+	if bytes.Contains(out, gpsMarker) {
+		t.Fatal("GPS data still present after strip")
+	}
+}
+
+func TestStripHEIFRejectsMalformed(t *testing.T) {
+	ftyp := heifBox("ftyp", []byte("heic"), u32(0))
+	data, _, _ := testHEIF()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"mdat before meta", append(append([]byte{}, ftyp...), heifBox("mdat", []byte("x"))...)},
+		{"no meta", ftyp},
+		{"size below header", append(append([]byte{}, ftyp...), 0, 0, 0, 4, 'f', 'r', 'e', 'e')},
+		{"64-bit size below header", append(append([]byte{}, ftyp...), append([]byte{0, 0, 0, 1, 'f', 'r', 'e', 'e'}, u64(8)...)...)},
+		{"64-bit size negative", append(append([]byte{}, ftyp...), append([]byte{0, 0, 0, 1, 'f', 'r', 'e', 'e'}, u64(1<<63)...)...)},
+		{"meta size to end of file", append(append([]byte{}, ftyp...), 0, 0, 0, 0, 'm', 'e', 't', 'a')},
+		{"meta too large", append(append([]byte{}, ftyp...), append([]byte{0, 0, 0, 1, 'm', 'e', 't', 'a'}, u64(maxHEIFMetaSize+17)...)...)},
+		{"truncated", data[:len(data)-4]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := strip("image/heic", tt.data); !errors.Is(err, ErrInvalidImage) {
+				t.Fatalf("err = %v, want ErrInvalidImage", err)
+			}
+		})
+	}
+}
+
+func TestHEIFMetadataRangesRejectsMalformed(t *testing.T) {
+	// base_offset 8바이트를 쓰는 iloc: 항목 하나, extent 하나
+	ilocWithBase := func(baseOffset uint64, offset, length uint32) []byte {
+		payload := append(append([]byte{}, fullBoxV0...), 0x44, 0x80)
+		payload = append(payload, u16(1)...)
+		payload = append(payload, u16(1)...)
+		payload = append(payload, u16(0)...)
+		payload = append(payload, u64(baseOffset)...)
+		payload = append(payload, u16(1)...)
+		payload = append(payload, u32(offset)...)
+		payload = append(payload, u32(length)...)
+		return heifBox("iloc", payload)
+	}
+
+	// 크기 필드가 모두 0이면 extent 가 바이트를 쓰지 않는다
+	manyExtents := append(append([]byte{}, fullBoxV0...), 0x00, 0x00)
+	manyExtents = append(manyExtents, u16(2)...)
+	for id := uint16(1); id <= 2; id++ {
+		manyExtents = append(manyExtents, u16(id)...)
+		manyExtents = append(manyExtents, u16(0)...)
+		manyExtents = append(manyExtents, u16(0xFFFF)...)
+	}
+
+	// iloc 버전 1, construction_method 1(idat) 인데 idat 이 없다
+	idatMethod := append([]byte{1, 0, 0, 0}, 0x44, 0x00)
+	idatMethod = append(idatMethod, u16(1)...)
+	idatMethod = append(idatMethod, u16(1)...)
+	idatMethod = append(idatMethod, u16(1)...)
+	idatMethod = append(idatMethod, u16(0)...)
+	idatMethod = append(idatMethod, u16(1)...)
+	idatMethod = append(idatMethod, u32(0)...)
+	idatMethod = append(idatMethod, u32(4)...)
+
+	// 8바이트 length 가 음수로 읽힌다
+	negativeLength := append(append([]byte{}, fullBoxV0...), 0x48, 0x00)
+	negativeLength = append(negativeLength, u16(1)...)
+	negativeLength = append(negativeLength, u16(1)...)
+	negativeLength = append(negativeLength, u16(0)...)
+	negativeLength = append(negativeLength, u16(1)...)
+	negativeLength = append(negativeLength, u32(0)...)
+	negativeLength = append(negativeLength, u64(1<<63)...)
+
+	exifItem := heifIinf(heifInfe(1, "Exif"))
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"shorter than version", []byte{0, 0}},
+		{"child overruns payload", append(append([]byte{}, fullBoxV0...), heifBox("iinf", make([]byte, 8))[:12]...)},
+		{"child size below header", append(append([]byte{}, fullBoxV0...), 0, 0, 0, 4, 'i', 'i', 'n', 'f')},
+		{"64-bit child size truncated", append(append([]byte{}, fullBoxV0...), 0, 0, 0, 1, 'i', 'd', 'a', 't', 0, 0)},
+		// off+size 가 int 범위를 넘어 음수가 되는 경우 (이전에는 슬라이싱에서 패닉)
+		{"64-bit child size overflows offset", bytes.Join([][]byte{
+			fullBoxV0, heifBox("free"), {0, 0, 0, 1, 'i', 'd', 'a', 't'}, u64(0x7FFFFFFFFFFFFFF8),
+		}, nil)},
+		{"64-bit child size exceeds int", bytes.Join([][]byte{
+			fullBoxV0, {0, 0, 0, 1, 'i', 'd', 'a', 't'}, u64(1<<64 - 1),
+		}, nil)},
+		{"truncated iinf", heifMeta(heifBox("iinf", []byte{0, 0}))[8:]},
+		{"truncated infe", heifMeta(heifIinf(heifBox("infe", []byte{2, 0, 0, 0, 0})))[8:]},
+		{"iloc field size not 0, 4 or 8", heifMeta(heifBox("iloc", fullBoxV0, []byte{0x34, 0x00}, u16(0)))[8:]},
+		{"iloc truncated", heifMeta(exifItem, heifBox("iloc", fullBoxV0, []byte{0x44, 0x00}, u16(1), u16(1)))[8:]},
+		{"iloc extent offset overflows", heifMeta(exifItem, ilocWithBase(1<<63-1, 1, 4))[8:]},
+		{"iloc extent offset negative", heifMeta(exifItem, ilocWithBase(1<<63, 0, 4))[8:]},
+		{"iloc too many extents", heifMeta(exifItem, heifBox("iloc", manyExtents))[8:]},
+		{"exif extent empty", heifMeta(exifItem, heifIlocV0([3]uint32{1, 100, 0}))[8:]},
+		{"exif extent length negative", heifMeta(exifItem, heifBox("iloc", negativeLength))[8:]},
+		{"exif in idat without idat", heifMeta(exifItem, heifBox("iloc", idatMethod))[8:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := heifMetadataRanges(tt.payload, 0); !errors.Is(err, ErrInvalidImage) {
+				t.Fatalf("err = %v, want ErrInvalidImage", err)
+			}
+		})
+	}
+}
+
+func TestHEIFMetadataRangesIdat(t *testing.T) {
+	// 버전 1 iloc: Exif 항목이 idat 안의 오프셋 2에서 4바이트
+	iloc := append([]byte{1, 0, 0, 0}, 0x44, 0x00)
+	iloc = append(iloc, u16(1)...)
+	iloc = append(iloc, u16(1)...)
+	iloc = append(iloc, u16(1)...)
+	iloc = append(iloc, u16(0)...)
+	iloc = append(iloc, u16(1)...)
+	iloc = append(iloc, u32(2)...)
+	iloc = append(iloc, u32(4)...)
+
+	idat := heifBox("idat", []byte("xxEXIFyy"))
+	iinf := heifIinf(heifInfe(1, "Exif"))
+	payload := heifMeta(iinf, heifBox("iloc", iloc), idat)[8:]
+
+	const payloadStart = 1000
+	ranges, err := heifMetadataRanges(payload, payloadStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idatData := int64(payloadStart + bytes.Index(payload, []byte("xxEXIFyy")))
+	want := byteRange{start: idatData + 2, end: idatData + 6}
+	if len(ranges) != 1 || ranges[0] != want {
+		t.Fatalf("ranges = %v, want [%v]", ranges, want)
+	}
+}
+
+func TestHEIFMetadataRangesXMP(t *testing.T) {
+	xmp := heifInfe(3, "mime", []byte("application/rdf+xml\x00")...)
+	other := heifInfe(4, "mime", []byte("image/jpeg\x00")...)
+	payload := heifMeta(heifIinf(xmp, other), heifIlocV0([3]uint32{3, 500, 10}, [3]uint32{4, 600, 10}))[8:]
+
+	ranges, err := heifMetadataRanges(payload, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 1 || ranges[0] != (byteRange{start: 500, end: 510}) {
+		t.Fatalf("ranges = %v, want only the XMP item", ranges)
+	}
+}
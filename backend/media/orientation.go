@@ -0,0 +1,88 @@
+package media
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// exifOrientation 은 TIFF 구조(EXIF 본문)의 IFD0에서 Orientation(0x0112) 값을 읽는다.
+// 찾지 못하면 1(정방향)을 반환한다.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// applyOrientation 은 EXIF Orientation에 맞게 픽셀을 회전/반전해
+// 메타데이터 없이도 똑바로 보이는 이미지를 만든다.
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	flat := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Src)
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 좌우 반전
+				sx, sy = w-1-x, y
+			case 3: // 180도
+				sx, sy = w-1-x, h-1-y
+			case 4: // 상하 반전
+				sx, sy = x, h-1-y
+			case 5: // 주대각선 기준 반전
+				sx, sy = y, x
+			case 6: // 시계 방향 90도
+				sx, sy = y, h-1-x
+			case 7: // 부대각선 기준 반전
+				sx, sy = w-1-y, h-1-x
+			case 8: // 반시계 방향 90도
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], flat.Pix[flat.PixOffset(sx, sy):flat.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
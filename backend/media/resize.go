@@ -0,0 +1,85 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Flatten 은 이미지를 (0,0)에서 시작하는 RGBA로 옮긴다.
+// 투명 배경은 JPEG로 저장할 때 검게 변하지 않도록 흰색으로 채운다.
+func Flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+	return flat
+}
+
+// Resize 는 Flatten 한 이미지를 긴 변이 maxDim이 되도록 비율을 유지하며 축소한다.
+// 축소 전용 박스 필터(영역 평균)라 별도 라이브러리 없이도 계단 현상이 적다.
+// 이미 maxDim 이하이면 flat 을 그대로 반환한다.
+func Resize(flat *image.RGBA, maxDim int) *image.RGBA {
+	bounds := flat.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := fitWithin(srcW, srcH, maxDim)
+	if dstW == srcW && dstH == srcH {
+		return flat
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := y * srcH / dstH
+		y1 := (y + 1) * srcH / dstH
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dstW; x++ {
+			x0 := x * srcW / dstW
+			x1 := (x + 1) * srcW / dstW
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				offset := flat.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(flat.Pix[offset])
+					g += uint32(flat.Pix[offset+1])
+					b += uint32(flat.Pix[offset+2])
+					a += uint32(flat.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+func fitWithin(w, h, maxDim int) (int, int) {
+	if w <= maxDim && h <= maxDim {
+		return w, h
+	}
+	if w >= h {
+		nh := h * maxDim / w
+		if nh < 1 {
+			nh = 1
+		}
+		return maxDim, nh
+	}
+	nw := w * maxDim / h
+	if nw < 1 {
+		nw = 1
+	}
+	return nw, maxDim
+}
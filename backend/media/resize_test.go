@@ -0,0 +1,64 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFlattenFillsTransparencyWithWhite(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(5, 5, 7, 6))
+	src.Set(6, 5, color.NRGBA{255, 0, 0, 255})
+
+	flat := Flatten(src)
+	if b := flat.Bounds(); b != image.Rect(0, 0, 2, 1) {
+		t.Fatalf("bounds = %v, want (0,0)-(2,1)", b)
+	}
+	if got := flat.RGBAAt(0, 0); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Fatalf("transparent pixel = %v, want white", got)
+	}
+	if got := flat.RGBAAt(1, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Fatalf("opaque pixel = %v, want red", got)
+	}
+}
+
+func TestResize(t *testing.T) {
+	flat := Flatten(testImage(400, 100))
+
+	tests := []struct {
+		maxDim        int
+		width, height int
+	}{
+		{800, 400, 100},
+		{400, 400, 100},
+		{200, 200, 50},
+		{32, 32, 8},
+		{2, 2, 1},
+	}
+
+	for _, tt := range tests {
+		got := Resize(flat, tt.maxDim).Bounds()
+		if got.Dx() != tt.width || got.Dy() != tt.height {
+			t.Errorf("Resize(%d) = %dx%d, want %dx%d", tt.maxDim, got.Dx(), got.Dy(), tt.width, tt.height)
+		}
+	}
+
+	// 축소할 필요가 없으면 복사하지 않는다
+	if Resize(flat, 400) != flat {
+		t.Error("Resize copied an image that already fits")
+	}
+
+	// 박스 필터는 단색 영역의 색을 그대로 유지한다
+	solid := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	for i := 0; i < len(solid.Pix); i += 4 {
+		copy(solid.Pix[i:], []uint8{10, 200, 30, 255})
+	}
+	small := Resize(solid, 7)
+	for y := 0; y < small.Bounds().Dy(); y++ {
+		for x := 0; x < small.Bounds().Dx(); x++ {
+			if got := small.RGBAAt(x, y); got != (color.RGBA{10, 200, 30, 255}) {
+				t.Fatalf("pixel (%d,%d) = %v, want solid color", x, y, got)
+			}
+		}
+	}
+}
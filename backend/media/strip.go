@@ -0,0 +1,255 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+var ErrInvalidImage = errors.New("media: malformed image")
+
+// StripMetadata 는 업로드 스트림에서 위치 정보 등이 담긴 메타데이터를 제거한다.
+//   - JPEG: EXIF/XMP(APP1), IPTC(APP13), 주석(COM) 등을 버린다. JFIF, ICC 프로파일, Adobe 세그먼트는 유지한다.
+//   - PNG: eXIf, tEXt, zTXt, iTXt, tIME 청크를 버린다.
+//   - HEIC/HEIF: 구조를 유지한 채 Exif/XMP 항목의 바이트를 0으로 덮는다. 회전은 irot 속성으로 표현되어 그대로 남는다.
+//
+// JPEG/PNG의 EXIF 방향 값이 정방향이 아니면 픽셀에 회전을 적용해 다시 인코딩한다.
+// 그 외 형식은 그대로 통과시킨다. 처리는 파이프로 스트리밍되므로 반환된 리더는 반드시 Close해야 한다.
+func StripMetadata(mimeType string, r io.Reader) io.ReadCloser {
+	var strip func(io.Writer, *bufio.Reader) error
+	switch mimeType {
+	case "image/jpeg":
+		strip = stripJPEG
+	case "image/png":
+		strip = stripPNG
+	case "image/heic", "image/heif":
+		strip = stripHEIF
+	default:
+		return io.NopCloser(r)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		// 파서가 잘못된 입력으로 패닉하더라도 서버 전체가 죽지 않게 한다
+		defer func() {
+			if v := recover(); v != nil {
+				pw.CloseWithError(fmt.Errorf("%w: %v", ErrInvalidImage, v))
+			}
+		}()
+
+		err := strip(pw, bufio.NewReaderSize(r, 64<<10))
+		// 크기 초과는 호출한 쪽이 413으로 구분할 수 있도록 그대로 둔다
+		if err != nil && !errors.Is(err, ErrTooLarge) && !errors.Is(err, ErrInvalidImage) {
+			err = fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+func stripJPEG(w io.Writer, r *bufio.Reader) error {
+	var header bytes.Buffer
+
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(r, soi); err != nil {
+		return err
+	}
+	if soi[0] != 0xFF || soi[1] != 0xD8 {
+		return ErrInvalidImage
+	}
+	header.Write(soi)
+
+	orientation := 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != 0xFF {
+			return ErrInvalidImage
+		}
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if marker, err = r.ReadByte(); err != nil {
+				return err
+			}
+		}
+
+		// 길이가 없는 마커
+		if marker == 0xD9 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			header.Write([]byte{0xFF, marker})
+			if marker == 0xD9 {
+				_, err := w.Write(header.Bytes())
+				return err
+			}
+			continue
+		}
+
+		var lengthBytes [2]byte
+		if _, err := io.ReadFull(r, lengthBytes[:]); err != nil {
+			return err
+		}
+		length := int(binary.BigEndian.Uint16(lengthBytes[:]))
+		if length < 2 {
+			return ErrInvalidImage
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return err
+		}
+
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			orientation = exifOrientation(segment[6:])
+		}
+		if dropJPEGSegment(marker, segment) {
+			continue
+		}
+
+		header.Write([]byte{0xFF, marker})
+		header.Write(lengthBytes[:])
+		header.Write(segment)
+
+		// SOS 이후는 압축된 이미지 데이터이므로 그대로 흘려보낸다
+		if marker == 0xDA {
+			rest := io.MultiReader(&header, r)
+			if orientation > 1 {
+				if err := checkDecodeSize(header.Bytes(), jpeg.DecodeConfig); err != nil {
+					return err
+				}
+				img, err := jpeg.Decode(rest)
+				if err != nil {
+					return err
+				}
+				return jpeg.Encode(w, applyOrientation(img, orientation), &jpeg.Options{Quality: 92})
+			}
+			_, err := io.Copy(w, rest)
+			return err
+		}
+	}
+}
+
+// checkDecodeSize 는 회전하려고 전체를 디코딩하기 전에 헤더의 크기로 압축 폭탄을 거른다.
+// 회전하면 같은 크기의 이미지를 하나 더 만들므로 ProcessImage 와 같은 한도를 적용한다.
+func checkDecodeSize(header []byte, decodeConfig func(io.Reader) (image.Config, error)) error {
+	cfg, err := decodeConfig(bytes.NewReader(header))
+	if err != nil {
+		return err
+	}
+	if cfg.Width*cfg.Height > maxDecodePixels {
+		return fmt.Errorf("%w: %w", ErrInvalidImage, ErrImageTooLarge)
+	}
+	return nil
+}
+
+func dropJPEGSegment(marker byte, segment []byte) bool {
+	switch {
+	case marker == 0xFE: // COM
+		return true
+	case marker == 0xE0: // JFIF/JFXX
+		return false
+	case marker == 0xE2: // ICC 프로파일만 유지 (FlashPix 등은 버림)
+		return !bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00"))
+	case marker == 0xEE: // Adobe: CMYK 색 변환 정보
+		return false
+	case marker >= 0xE1 && marker <= 0xEF:
+		return true
+	}
+	return false
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// PNG 메타데이터 청크. 이 외의 청크(보조 청크 포함)는 렌더링에 쓰이므로 유지한다.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(w io.Writer, r *bufio.Reader) error {
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil {
+		return err
+	}
+	if !bytes.Equal(signature, pngSignature) {
+		return ErrInvalidImage
+	}
+
+	// 첫 IDAT 전까지는 모아 두었다가, 방향 값이 있으면 디코딩해 회전한다
+	var header bytes.Buffer
+	header.Write(signature)
+	out := io.Writer(&header)
+	orientation := 1
+	seenIDAT := false
+
+	for {
+		var chunkHeader [8]byte
+		if _, err := io.ReadFull(r, chunkHeader[:]); err != nil {
+			return err
+		}
+		length := binary.BigEndian.Uint32(chunkHeader[:4])
+		chunkType := string(chunkHeader[4:8])
+		if length > 1<<31-1 {
+			return ErrInvalidImage
+		}
+
+		if !seenIDAT && chunkType == "IDAT" {
+			seenIDAT = true
+			if orientation > 1 {
+				if err := checkDecodeSize(header.Bytes(), png.DecodeConfig); err != nil {
+					return err
+				}
+				rest := io.MultiReader(&header, bytes.NewReader(chunkHeader[:]), r)
+				img, err := png.Decode(rest)
+				if err != nil {
+					return err
+				}
+				return png.Encode(w, applyOrientation(img, orientation))
+			}
+			if _, err := w.Write(header.Bytes()); err != nil {
+				return err
+			}
+			out = w
+		}
+
+		if pngMetadataChunks[chunkType] {
+			if chunkType == "eXIf" && !seenIDAT && length <= 1<<20 {
+				data := make([]byte, length)
+				if _, err := io.ReadFull(r, data); err != nil {
+					return err
+				}
+				orientation = exifOrientation(data)
+				if _, err := r.Discard(4); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(length)+4); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := out.Write(chunkHeader[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, r, int64(length)+4); err != nil {
+			return err
+		}
+
+		if chunkType == "IEND" {
+			if !seenIDAT {
+				return ErrInvalidImage
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// 위치 정보가 지워졌는지 확인할 때 찾는 문자열
+var gpsMarker = []byte("GPS:37.5665N,126.9780E")
+
+// exifTIFF 는 IFD0에 Orientation과 GPS IFD 포인터를, GPS IFD에 위도를 담은 EXIF 본문을 만든다.
+func exifTIFF(orientation int) []byte {
+	be := binary.BigEndian
+	var b bytes.Buffer
+	b.WriteString("MM")
+	binary.Write(&b, be, uint16(42))
+	binary.Write(&b, be, uint32(8))
+
+	// IFD0 (offset 8): 항목 2개
+	binary.Write(&b, be, uint16(2))
+	binary.Write(&b, be, []uint16{0x0112, 3})
+	binary.Write(&b, be, uint32(1))
+	binary.Write(&b, be, []uint16{uint16(orientation), 0})
+	binary.Write(&b, be, []uint16{0x8825, 4})
+	binary.Write(&b, be, uint32(1))
+	binary.Write(&b, be, uint32(38))
+	binary.Write(&b, be, uint32(0))
+
+	// GPS IFD (offset 38): GPSLatitude, 값은 offset 56의 RATIONAL 3개
+	binary.Write(&b, be, uint16(1))
+	binary.Write(&b, be, []uint16{0x0002, 5})
+	binary.Write(&b, be, uint32(3))
+	binary.Write(&b, be, uint32(56))
+	binary.Write(&b, be, uint32(0))
+	binary.Write(&b, be, []uint32{37, 1, 33, 1, 59, 1})
+
+	b.Write(gpsMarker)
+	return b.Bytes()
+}
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 40), uint8(y * 40), 128, 255})
+		}
+	}
+	return img
+}
+
+func jpegSegment(marker byte, data []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(data)+2))
+	return append(segment, data...)
+}
+
+// testJPEG 는 SOI 바로 뒤에 segments를 끼운 JPEG를 만든다.
+func testJPEG(t *testing.T, w, h int, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	out := append([]byte{}, encoded[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, encoded[2:]...)
+}
+
+func exifSegment(orientation int) []byte {
+	return jpegSegment(0xE1, append([]byte("Exif\x00\x00"), exifTIFF(orientation)...))
+}
+
+// setJPEGSize 는 SOF0의 크기 필드만 바꾼다 (픽셀 데이터는 그대로).
+func setJPEGSize(t *testing.T, data []byte, w, h int) {
+	t.Helper()
+	i := bytes.Index(data, []byte{0xFF, 0xC0})
+	if i < 0 {
+		t.Fatal("SOF0 not found")
+	}
+	binary.BigEndian.PutUint16(data[i+5:], uint16(h))
+	binary.BigEndian.PutUint16(data[i+7:], uint16(w))
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// testPNG 는 IHDR 바로 뒤에 chunks를 끼운 PNG를 만든다.
+func testPNG(t *testing.T, w, h int, chunks ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	out := append([]byte{}, encoded[:ihdrEnd]...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	return append(out, encoded[ihdrEnd:]...)
+}
+
+// setPNGSize 는 IHDR의 크기 필드를 바꾸고 CRC를 다시 계산한다.
+func setPNGSize(data []byte, w, h int) {
+	ihdr := data[len(pngSignature):]
+	binary.BigEndian.PutUint32(ihdr[8:], uint32(w))
+	binary.BigEndian.PutUint32(ihdr[12:], uint32(h))
+	binary.BigEndian.PutUint32(ihdr[21:], crc32.ChecksumIEEE(ihdr[4:21]))
+}
+
+func strip(mimeType string, data []byte) ([]byte, error) {
+	r := StripMetadata(mimeType, bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestStripRemovesGPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+	}{
+		{"jpeg", "image/jpeg", testJPEG(t, 4, 2,
+			exifSegment(1),
+			jpegSegment(0xE1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), gpsMarker...)),
+			jpegSegment(0xFE, gpsMarker))},
+		{"png", "image/png", testPNG(t, 4, 2,
+			pngChunk("eXIf", exifTIFF(1)),
+			pngChunk("tEXt", append([]byte("Comment\x00"), gpsMarker...)),
+			pngChunk("iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), gpsMarker...)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Contains(tt.data, gpsMarker) {
+				t.Fatal("fixture has no GPS data")
+			}
+
+			out, err := strip(tt.mimeType, tt.data)
+			if err != nil {
+				t.Fatalf("strip: %v", err)
+			}
+			if bytes.Contains(out, gpsMarker) || bytes.Contains(out, []byte("Exif")) || bytes.Contains(out, []byte("eXIf")) {
+				t.Fatal("metadata still present after strip")
+			}
+
+			img, _, err := image.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("decode stripped image: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+				t.Fatalf("size = %dx%d, want 4x2", b.Dx(), b.Dy())
+			}
+		})
+	}
+}
+
+func TestStripAppliesOrientation(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+	}{
+		{"jpeg", "image/jpeg", testJPEG(t, 4, 2, exifSegment(6))},
+		{"png", "image/png", testPNG(t, 4, 2, pngChunk("eXIf", exifTIFF(6)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := strip(tt.mimeType, tt.data)
+			if err != nil {
+				t.Fatalf("strip: %v", err)
+			}
+			if bytes.Contains(out, gpsMarker) {
+				t.Fatal("GPS data still present after rotation")
+			}
+			img, _, err := image.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+				t.Fatalf("size = %dx%d, want 2x4", b.Dx(), b.Dy())
+			}
+		})
+	}
+}
+
+func TestStripRejectsMalformed(t *testing.T) {
+	oversizedJPEG := testJPEG(t, 4, 2, exifSegment(6))
+	setJPEGSize(t, oversizedJPEG, 65535, 65535)
+	oversizedPNG := testPNG(t, 4, 2, pngChunk("eXIf", exifTIFF(6)))
+	setPNGSize(oversizedPNG, 65535, 65535)
+
+	validJPEG := testJPEG(t, 4, 2, exifSegment(1))
+	validPNG := testPNG(t, 4, 2)
+
+	hugeChunk := append([]byte{}, pngSignature...)
+	hugeChunk = append(hugeChunk, 0xFF, 0xFF, 0xFF, 0xFF, 't', 'E', 'X', 't')
+
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+		tooLarge bool
+	}{
+		{"jpeg not jpeg", "image/jpeg", []byte("GIF89a"), false},
+		{"jpeg truncated segment", "image/jpeg", validJPEG[:30], false},
+		{"jpeg segment length below 2", "image/jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01}, false},
+		{"jpeg garbage between segments", "image/jpeg", []byte{0xFF, 0xD8, 0x00, 0xFF}, false},
+		{"jpeg oversized rotation", "image/jpeg", oversizedJPEG, true},
+		{"png bad signature", "image/png", []byte("\x89PNG\r\n\x1a\x00"), false},
+		{"png truncated chunk", "image/png", validPNG[:len(pngSignature)+20], false},
+		{"png chunk length overflow", "image/png", hugeChunk, false},
+		{"png no IDAT", "image/png", append(append([]byte{}, pngSignature...), pngChunk("IEND", nil)...), false},
+		{"png oversized rotation", "image/png", oversizedPNG, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := strip(tt.mimeType, tt.data)
+			if !errors.Is(err, ErrInvalidImage) {
+				t.Fatalf("err = %v, want ErrInvalidImage", err)
+			}
+			if tt.tooLarge && !errors.Is(err, ErrImageTooLarge) {
+				t.Fatalf("err = %v, want ErrImageTooLarge", err)
+			}
+		})
+	}
+}
+
+// 회전할 필요가 없으면 디코딩하지 않으므로 큰 이미지도 메타데이터만 지우고 통과시킨다
+func TestStripOversizedWithoutRotation(t *testing.T) {
+	data := testJPEG(t, 4, 2, exifSegment(1))
+	setJPEGSize(t, data, 65535, 65535)
+
+	out, err := strip("image/jpeg", data)
+	if err != nil {
+		t.Fatalf("strip: %v", err)
+	}
+	if bytes.Contains(out, gpsMarker) {
+		t.Fatal("GPS data still present")
+	}
+}
+
+func TestExifOrientation(t *testing.T) {
+	little := exifTIFF(8)
+	little[0], little[1] = 'I', 'I' // 바이트 순서만 바꾼 잘못된 본문
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"rotate 90", exifTIFF(6), 6},
+		{"normal", exifTIFF(1), 1},
+		{"out of range value", exifTIFF(9), 1},
+		{"too short", []byte("MM\x00"), 1},
+		{"unknown byte order", append([]byte("XX"), exifTIFF(6)[2:]...), 1},
+		{"IFD offset past end", []byte("MM\x00\x2a\xff\xff\xff\xf0"), 1},
+		{"IFD entries truncated", exifTIFF(6)[:20], 1},
+		{"wrong byte order", little, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != tt.want {
+				t.Fatalf("exifOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
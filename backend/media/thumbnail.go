@@ -0,0 +1,108 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"messenger/storage"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// 긴 변 기준 픽셀 크기. ?size= 파라미터 값으로 쓰인다.
+var ThumbnailSizes = map[string]int{
+	"small":  200,
+	"medium": 640,
+	"large":  1280,
+}
+
+// 디코딩 전에 헤더만 보고 거르는 최대 픽셀 수 (압축 폭탄 방지)
+const maxDecodePixels = 50_000_000
+
+var ErrImageTooLarge = errors.New("media: image dimensions too large")
+
+type ImageInfo struct {
+	Width    int
+	Height   int
+	Blurhash string
+}
+
+func ThumbnailKey(key, size string) string {
+	return key + "_" + size
+}
+
+// ProcessImage 는 저장된 원본 이미지를 읽어 원본보다 작은 표준 크기의 썸네일(JPEG)을 저장하고,
+// 원본 크기와 blurhash 플레이스홀더를 반환한다.
+func ProcessImage(ctx context.Context, store storage.Storage, key string) (ImageInfo, error) {
+	object, _, err := store.Open(ctx, key)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	defer object.Close()
+
+	cfg, _, err := image.DecodeConfig(object)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	if cfg.Width*cfg.Height > maxDecodePixels {
+		return ImageInfo{}, ErrImageTooLarge
+	}
+
+	if _, err := object.Seek(0, 0); err != nil {
+		return ImageInfo{}, err
+	}
+
+	src, _, err := image.Decode(object)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	return StoreThumbnails(ctx, store, key, src)
+}
+
+// StoreThumbnails 는 이미 디코딩된 이미지로 썸네일과 메타데이터를 만든다.
+func StoreThumbnails(ctx context.Context, store storage.Storage, key string, src image.Image) (ImageInfo, error) {
+	bounds := src.Bounds()
+	info := ImageInfo{Width: bounds.Dx(), Height: bounds.Dy()}
+
+	// 크기마다 새로 만들지 않도록 한 번만 RGBA로 옮긴다
+	flat := Flatten(src)
+	for name, maxDim := range ThumbnailSizes {
+		if info.Width <= maxDim && info.Height <= maxDim {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, Resize(flat, maxDim), &jpeg.Options{Quality: 80}); err != nil {
+			return info, err
+		}
+		if _, err := store.Put(ctx, ThumbnailKey(key, name), &buf, "image/jpeg"); err != nil {
+			return info, fmt.Errorf("failed to store %s thumbnail: %w", name, err)
+		}
+	}
+
+	info.Blurhash = Blurhash(Resize(flat, 32), 4, 3)
+	return info, nil
+}
+
+// ThumbnailFor 는 요청한 크기에 맞는 객체 키를 고른다.
+// 원본이 요청 크기보다 작으면 썸네일을 만들지 않았으므로 원본 키를 그대로 쓴다.
+func ThumbnailFor(key, size string, width, height int) (string, bool) {
+	maxDim, ok := ThumbnailSizes[size]
+	if !ok || (width <= maxDim && height <= maxDim) {
+		return key, false
+	}
+	return ThumbnailKey(key, size), true
+}
+
+// DeleteWithThumbnails 는 원본과 모든 크기의 썸네일을 지운다.
+func DeleteWithThumbnails(ctx context.Context, store storage.Storage, key string) {
+	store.Delete(ctx, key)
+	for name := range ThumbnailSizes {
+		store.Delete(ctx, ThumbnailKey(key, name))
+	}
+}
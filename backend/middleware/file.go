@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"messenger/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileAccess 는 서명된 URL이면 토큰 없이 통과시키고, 아니면 일반 JWT 인증을 요구한다.
+func FileAccess() gin.HandlerFunc {
+	authRequired := AuthRequired()
+
+	return func(c *gin.Context) {
+		signature := c.Query("signature")
+		if signature == "" {
+			authRequired(c)
+			return
+		}
+
+		if !services.VerifyFileSignature(c.Param("id"), c.Query("expires"), signature) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired signature"})
+			c.Abort()
+			return
+		}
+
+		c.Set("signed_url", true)
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type StorageReport struct {
+	BlobCount     int             `json:"blob_count"`
+	StoredBytes   int64           `json:"stored_bytes"`
+	LogicalBytes  int64           `json:"logical_bytes"`
+	SavedBytes    int64           `json:"saved_bytes"`
+	TopDuplicates []DuplicateBlob `json:"top_duplicates"`
+}
+
+type DuplicateBlob struct {
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	MimeType   string    `json:"mime_type"`
+	RefCount   int       `json:"ref_count"`
+	SavedBytes int64     `json:"saved_bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type QuarantinedBlob struct {
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	MimeType  string    `json:"mime_type"`
+	Signature string    `json:"signature"`
+	RefCount  int       `json:"ref_count"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
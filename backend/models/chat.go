@@ -3,12 +3,12 @@ package models
 import "time"
 
 type ChatRoom struct {
-	ID        int        `json:"id"`
-	Type      string     `json:"type"`
-	Name      string     `json:"name,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	Members   []RoomMember `json:"members,omitempty"`
-	LastMessage *Message  `json:"last_message,omitempty"`
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Name        string       `json:"name,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Members     []RoomMember `json:"members,omitempty"`
+	LastMessage *Message     `json:"last_message,omitempty"`
 }
 
 type RoomMember struct {
@@ -20,6 +20,7 @@ type RoomMember struct {
 	JoinedAt          time.Time  `json:"joined_at"`
 	LeftAt            *time.Time `json:"left_at,omitempty"`
 	LastReadMessageID int        `json:"last_read_message_id"`
+	Role              string     `json:"role"`
 }
 
 type CreateRoomRequest struct {
@@ -29,12 +30,100 @@ type CreateRoomRequest struct {
 }
 
 type ChatRoomListItem struct {
-	ID           int       `json:"id"`
-	Type         string    `json:"type"`
-	Name         string    `json:"name"`
-	LastMessage  string    `json:"last_message,omitempty"`
-	LastSender   string    `json:"last_sender,omitempty"`
-	LastTime     *time.Time `json:"last_time,omitempty"`
-	MemberCount  int       `json:"member_count"`
-	IsMuted      bool      `json:"is_muted"`
+	ID                int               `json:"id"`
+	Type              string            `json:"type"`
+	Name              string            `json:"name"`
+	LastMessage       string            `json:"last_message,omitempty"`
+	LastSender        string            `json:"last_sender,omitempty"`
+	LastTime          *time.Time        `json:"last_time,omitempty"`
+	MemberCount       int               `json:"member_count"`
+	IsMuted           bool              `json:"is_muted"`
+	NotificationLevel string            `json:"notification_level"`
+	MutedUntil        *time.Time        `json:"muted_until,omitempty"`
+	IsPinned          bool              `json:"is_pinned"`
+	IsArchived        bool              `json:"is_archived"`
+	FolderID          *int              `json:"folder_id,omitempty"`
+	PinnedMessages    []PinnedMessage   `json:"pinned_messages"`
+	Announcement      *RoomAnnouncement `json:"announcement,omitempty"`
+}
+
+type RoomDetail struct {
+	ID                int               `json:"id"`
+	Type              string            `json:"type"`
+	Name              string            `json:"name,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	MyRole            string            `json:"my_role"`
+	IsMuted           bool              `json:"is_muted"`
+	NotificationLevel string            `json:"notification_level"`
+	MutedUntil        *time.Time        `json:"muted_until,omitempty"`
+	Members           []RoomMember      `json:"members"`
+	PinnedMessages    []PinnedMessage   `json:"pinned_messages"`
+	Announcement      *RoomAnnouncement `json:"announcement,omitempty"`
+}
+
+type PinnedMessage struct {
+	MessageID  int       `json:"message_id"`
+	RoomID     int       `json:"room_id"`
+	SenderID   int       `json:"sender_id"`
+	SenderName string    `json:"sender_name,omitempty"`
+	Content    string    `json:"content,omitempty"`
+	Type       string    `json:"type"`
+	CreatedAt  time.Time `json:"created_at"`
+	PinnedBy   int       `json:"pinned_by"`
+	PinnedAt   time.Time `json:"pinned_at"`
+}
+
+type RoomAnnouncement struct {
+	ID            int       `json:"id"`
+	RoomID        int       `json:"room_id"`
+	Content       string    `json:"content"`
+	CreatedBy     int       `json:"created_by"`
+	CreatedByName string    `json:"created_by_name,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	Dismissed     bool      `json:"dismissed"`
+}
+
+type PinMessageRequest struct {
+	MessageID int `json:"message_id" binding:"required"`
+}
+
+type SetAnnouncementRequest struct {
+	Content string `json:"content" binding:"required,max=1000"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
+}
+
+type ChatFolder struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	RoomCount int       `json:"room_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FolderRequest struct {
+	Name     string `json:"name" binding:"required,max=50"`
+	Position int    `json:"position"`
+}
+
+// FolderID가 0이면 폴더에서 뺀다
+type UpdateRoomPreferencesRequest struct {
+	IsPinned   *bool `json:"is_pinned"`
+	IsArchived *bool `json:"is_archived"`
+	FolderID   *int  `json:"folder_id"`
+}
+
+var MuteDurations = map[string]time.Duration{
+	"1h": time.Hour,
+	"8h": 8 * time.Hour,
+}
+
+// Level이 "all"이면 음소거를 해제한다.
+// Until이 있으면 Duration보다 우선한다.
+type MuteRequest struct {
+	Level    string     `json:"level" binding:"omitempty,oneof=all muted mentions"`
+	Duration string     `json:"duration"`
+	Until    *time.Time `json:"until"`
 }
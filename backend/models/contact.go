@@ -0,0 +1,22 @@
+package models
+
+// Full 이면 Added 가 전체 연락처이고 그 외의 기존 연락처는 지운다.
+// 아니면 Added/Removed 로 지난 동기화 이후 바뀐 것만 보낸다.
+type ContactSyncRequest struct {
+	Full    bool     `json:"full"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+type ContactMatch struct {
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	PhoneHash string `json:"phone_hash"`
+	IsFriend  bool   `json:"is_friend"`
+}
+
+type ContactSyncResponse struct {
+	Matches      []ContactMatch `json:"matches"`
+	ContactCount int            `json:"contact_count"`
+}
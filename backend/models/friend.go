@@ -9,14 +9,47 @@ type Friend struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// Name 은 별명이 있으면 별명이다
 type FriendWithUser struct {
-	ID        int       `json:"id"`
-	FriendID  int       `json:"friend_id"`
-	Username  string    `json:"username"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int       `json:"id"`
+	FriendID   int       `json:"friend_id"`
+	Username   string    `json:"username"`
+	Name       string    `json:"name"`
+	Nickname   string    `json:"nickname,omitempty"`
+	IsFavorite bool      `json:"is_favorite"`
+	IsHidden   bool      `json:"is_hidden"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type UpdateFriendPreferencesRequest struct {
+	IsFavorite *bool   `json:"is_favorite"`
+	IsHidden   *bool   `json:"is_hidden"`
+	Nickname   *string `json:"nickname"`
 }
 
 type AddFriendRequest struct {
 	Query string `json:"query" binding:"required"`
 }
+
+// FriendRequest 의 UserID/Username/Name 은 조회한 사용자 입장에서 상대방 정보다
+type FriendRequest struct {
+	ID          int        `json:"id"`
+	RequesterID int        `json:"requester_id"`
+	RecipientID int        `json:"recipient_id"`
+	Status      string     `json:"status"`
+	UserID      int        `json:"user_id"`
+	Username    string     `json:"username"`
+	Name        string     `json:"name"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+type FriendRecommendation struct {
+	UserID        int    `json:"user_id"`
+	Username      string `json:"username"`
+	Name          string `json:"name"`
+	MutualFriends int    `json:"mutual_friends"`
+	SharedGroups  int    `json:"shared_groups"`
+	InContacts    bool   `json:"in_contacts"`
+	Score         int    `json:"score"`
+}
@@ -9,18 +9,55 @@ type Message struct {
 	SenderName string    `json:"sender_name,omitempty"`
 	Content    string    `json:"content,omitempty"`
 	Type       string    `json:"type"`
-	FileID     *int      `json:"file_id,omitempty"`
+	FileID     *string   `json:"file_id,omitempty"`
+	Width      *int      `json:"width,omitempty"`
+	Height     *int      `json:"height,omitempty"`
+	Blurhash   string    `json:"blurhash,omitempty"`
+	Filename   string    `json:"filename,omitempty"`
+	FileSize   int64     `json:"file_size,omitempty"`
+	Extension  string    `json:"extension,omitempty"`
+	DurationMS *int      `json:"duration_ms,omitempty"`
+	Waveform   []int     `json:"waveform,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
 type MessageFile struct {
-	ID        int       `json:"id"`
-	MessageID int       `json:"message_id"`
-	Filename  string    `json:"filename"`
-	MimeType  string    `json:"mime_type"`
-	FileData  []byte    `json:"-"`
-	FileSize  int       `json:"file_size"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int       `json:"id"`
+	MessageID  int       `json:"message_id"`
+	Filename   string    `json:"filename"`
+	MimeType   string    `json:"mime_type"`
+	FileData   []byte    `json:"-"`
+	StorageKey string    `json:"-"`
+	FileSize   int64     `json:"file_size"`
+	Width      *int      `json:"width,omitempty"`
+	Height     *int      `json:"height,omitempty"`
+	Blurhash   string    `json:"blurhash,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// 채팅방 미디어/파일/링크 모아보기 항목
+type MediaItem struct {
+	MessageID    int       `json:"message_id"`
+	Type         string    `json:"type"`
+	SenderID     *int      `json:"sender_id,omitempty"`
+	SenderName   string    `json:"sender_name,omitempty"`
+	FileID       string    `json:"file_id,omitempty"`
+	Filename     string    `json:"filename,omitempty"`
+	FileSize     int64     `json:"file_size,omitempty"`
+	Extension    string    `json:"extension,omitempty"`
+	Width        *int      `json:"width,omitempty"`
+	Height       *int      `json:"height,omitempty"`
+	Blurhash     string    `json:"blurhash,omitempty"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	DurationMS   *int      `json:"duration_ms,omitempty"`
+	URLs         []string  `json:"urls,omitempty"`
+	Content      string    `json:"content,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type MediaPage struct {
+	Items      []MediaItem `json:"items"`
+	NextCursor *int        `json:"next_cursor"`
 }
 
 type SendMessageRequest struct {
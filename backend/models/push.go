@@ -0,0 +1,10 @@
+package models
+
+type PushTokenRequest struct {
+	Platform string `json:"platform" binding:"required,oneof=fcm apns"`
+	Token    string `json:"token" binding:"required,max=512"`
+}
+
+type DeletePushTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
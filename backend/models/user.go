@@ -27,8 +27,9 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token    string `json:"token"`
+	User     User   `json:"user"`
+	Restored bool   `json:"restored,omitempty"` // 탈퇴 유예 기간 중 로그인해 계정이 복구됨
 }
 
 type SendCodeRequest struct {
@@ -41,9 +42,76 @@ type VerifyCodeRequest struct {
 }
 
 type UpdateProfileRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name          *string `json:"name"`
+	StatusMessage *string `json:"status_message"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type CompletePasswordResetRequest struct {
+	ResetToken  string `json:"reset_token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangePhoneRequest struct {
+	Phone           string `json:"phone" binding:"required"`
+	Code            string `json:"code" binding:"required,len=6"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type ChangeUsernameRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
 }
 
 type SearchUserRequest struct {
 	Query string `form:"q" binding:"required"`
 }
+
+type BlockedUser struct {
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+type BlockUserRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
+
+// 다른 사용자에게 보여주는 프로필. 공개 범위 밖의 항목은 비워서 내려간다.
+type UserProfile struct {
+	ID                 int                  `json:"id"`
+	Username           string               `json:"username"`
+	Name               string               `json:"name"`
+	StatusMessage      string               `json:"status_message,omitempty"`
+	HasProfileImage    bool                 `json:"has_profile_image"`
+	HasBackgroundImage bool                 `json:"has_background_image"`
+	IsFriend           bool                 `json:"is_friend"`
+	Online             *bool                `json:"online,omitempty"`
+	LastSeenAt         *time.Time           `json:"last_seen_at,omitempty"`
+	ProfileHistory     []ProfileHistoryItem `json:"profile_history,omitempty"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+}
+
+type ProfileHistoryItem struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Width     *int      `json:"width,omitempty"`
+	Height    *int      `json:"height,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LastSeenVisible 이 false 이면 Online/LastSeenAt 은 비어 있다
+type Presence struct {
+	UserID          int        `json:"user_id"`
+	LastSeenVisible bool       `json:"last_seen_visible"`
+	Online          *bool      `json:"online,omitempty"`
+	LastSeenAt      *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,164 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProductionHost  = "https://api.push.apple.com"
+	apnsDevelopmentHost = "https://api.sandbox.push.apple.com"
+)
+
+// APNsProvider 는 토큰 기반(.p8) 인증으로 APNs HTTP/2 API에 발송한다.
+// Apple은 provider token을 20분~60분 사이에 갱신하도록 요구한다.
+type APNsProvider struct {
+	host   string
+	keyID  string
+	teamID string
+	topic  string
+	key    *ecdsa.PrivateKey
+	client *http.Client
+
+	mu       sync.Mutex
+	jwtToken string
+	issuedAt time.Time
+}
+
+func NewAPNsProvider(keyFile, keyID, teamID, topic string, production bool) (*APNsProvider, error) {
+	if keyFile == "" || keyID == "" || teamID == "" || topic == "" {
+		return nil, errors.New("APNS_KEY_FILE, APNS_KEY_ID, APNS_TEAM_ID and APNS_TOPIC are required")
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read APNs key: %w", err)
+	}
+
+	key, err := jwt.ParseECPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse APNs key: %w", err)
+	}
+
+	host := apnsDevelopmentHost
+	if production {
+		host = apnsProductionHost
+	}
+
+	return &APNsProvider{
+		host:   host,
+		keyID:  keyID,
+		teamID: teamID,
+		topic:  topic,
+		key:    key,
+		// TLS 연결에서 net/http가 HTTP/2를 자동으로 협상한다
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *APNsProvider) Send(ctx context.Context, token string, n Notification) error {
+	providerToken, err := p.providerToken()
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": n.Title,
+				"body":  n.Body,
+			},
+			"sound": "default",
+		},
+	}
+	for k, v := range n.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, _ := json.Marshal(payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.host+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", p.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	var result struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result)
+	return p.classifyError(resp.StatusCode, result.Reason)
+}
+
+func (p *APNsProvider) classifyError(status int, reason string) error {
+	err := fmt.Errorf("apns: status %d: %s", status, reason)
+
+	switch {
+	case status == http.StatusGone,
+		reason == "BadDeviceToken",
+		reason == "Unregistered",
+		reason == "DeviceTokenNotForTopic":
+		return ErrInvalidToken
+	case reason == "ExpiredProviderToken":
+		p.mu.Lock()
+		p.jwtToken = ""
+		p.mu.Unlock()
+		return err
+	case status == http.StatusTooManyRequests || status >= 500:
+		return err
+	default:
+		return &PermanentError{Err: err}
+	}
+}
+
+func (p *APNsProvider) providerToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.jwtToken != "" && time.Since(p.issuedAt) < 50*time.Minute {
+		return p.jwtToken, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = p.keyID
+
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		return "", err
+	}
+
+	p.jwtToken = signed
+	p.issuedAt = now
+	return signed, nil
+}
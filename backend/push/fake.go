@@ -0,0 +1,62 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+type FakeDelivery struct {
+	Token        string
+	Notification Notification
+}
+
+// FakeProvider 는 실제로 발송하지 않고 기록만 남기는 로컬/테스트용 프로바이더다.
+type FakeProvider struct {
+	mu       sync.Mutex
+	sent     []FakeDelivery
+	invalid  map[string]bool
+	failNext int
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{invalid: make(map[string]bool)}
+}
+
+func (p *FakeProvider) Send(ctx context.Context, token string, n Notification) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.invalid[token] {
+		return ErrInvalidToken
+	}
+	if p.failNext > 0 {
+		p.failNext--
+		return errors.New("push: simulated transient failure")
+	}
+
+	log.Printf("[MOCK PUSH] Token: %s, Title: %s, Body: %s", token, n.Title, n.Body)
+	p.sent = append(p.sent, FakeDelivery{Token: token, Notification: n})
+	return nil
+}
+
+// MarkInvalid 이후 해당 토큰으로의 발송은 ErrInvalidToken을 반환한다.
+func (p *FakeProvider) MarkInvalid(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.invalid[token] = true
+}
+
+// FailNext 는 다음 n번의 발송을 재시도 가능한 오류로 실패시킨다.
+func (p *FakeProvider) FailNext(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failNext = n
+}
+
+func (p *FakeProvider) Sent() []FakeDelivery {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeDelivery(nil), p.sent...)
+}
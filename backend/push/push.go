@@ -0,0 +1,252 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"messenger/config"
+	"messenger/services"
+	"messenger/websocket"
+	"time"
+)
+
+const (
+	PlatformFCM  = "fcm"
+	PlatformAPNs = "apns"
+)
+
+// ErrInvalidToken 은 기기 토큰이 더 이상 유효하지 않을 때 반환되며, 해당 토큰은 삭제된다.
+var ErrInvalidToken = errors.New("push: invalid device token")
+
+// PermanentError 는 재시도해도 성공할 수 없는 실패를 나타낸다.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+type Notification struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+type Provider interface {
+	Send(ctx context.Context, token string, n Notification) error
+}
+
+type job struct {
+	userID       int
+	platform     string
+	token        string
+	notification Notification
+	attempt      int
+}
+
+type deviceToken struct {
+	platform string
+	token    string
+}
+
+type Dispatcher struct {
+	providers   map[string]Provider
+	queue       chan job
+	maxAttempts int
+	baseBackoff time.Duration
+
+	// 수신 여부 판단과 토큰 저장소. 기본값은 DB와 웹소켓 허브를 쓰고, 테스트에서 바꿔 끼운다.
+	isActive     func(userID int) bool
+	shouldNotify func(userID, roomID int, content string) bool
+	tokens       func(userID int) []deviceToken
+	pruneToken   func(token string)
+}
+
+var dispatcher *Dispatcher
+
+func NewDispatcher(providers map[string]Provider, workers int) *Dispatcher {
+	d := &Dispatcher{
+		providers:    providers,
+		queue:        make(chan job, 1024),
+		maxAttempts:  5,
+		baseBackoff:  time.Second,
+		isActive:     websocket.IsUserActive,
+		shouldNotify: services.ShouldNotify,
+		tokens:       loadDeviceTokens,
+		pruneToken:   deleteDeviceToken,
+	}
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Init 은 설정에 따라 프로바이더를 구성하고 전역 디스패처를 시작한다.
+// mock 모드에서는 모든 플랫폼이 FakeProvider로 연결된다.
+func Init() {
+	providers := map[string]Provider{}
+
+	if config.AppConfig.PushMode == "mock" {
+		fake := NewFakeProvider()
+		providers[PlatformFCM] = fake
+		providers[PlatformAPNs] = fake
+	} else {
+		if fcm, err := NewFCMProvider(config.AppConfig.FCMProjectID, config.AppConfig.FCMCredentialsFile); err != nil {
+			log.Printf("FCM push disabled: %v", err)
+		} else {
+			providers[PlatformFCM] = fcm
+		}
+
+		if apns, err := NewAPNsProvider(
+			config.AppConfig.APNsKeyFile,
+			config.AppConfig.APNsKeyID,
+			config.AppConfig.APNsTeamID,
+			config.AppConfig.APNsTopic,
+			config.AppConfig.APNsProduction,
+		); err != nil {
+			log.Printf("APNs push disabled: %v", err)
+		} else {
+			providers[PlatformAPNs] = apns
+		}
+	}
+
+	dispatcher = NewDispatcher(providers, 4)
+}
+
+// NotifyRoom 은 새 메시지를 보낸 사람을 제외한 멤버 중
+// 오프라인이거나 앱이 백그라운드인 사용자에게 푸시를 보낸다.
+func NotifyRoom(roomID, senderID int, content string, n Notification) {
+	if dispatcher == nil {
+		return
+	}
+
+	go func() {
+		rows, err := config.DB.Query(`
+			SELECT user_id FROM chat_room_members crm
+			WHERE room_id = $1 AND left_at IS NULL AND user_id != $2
+			AND NOT EXISTS (SELECT 1 FROM user_blocks ub WHERE ub.blocker_id = crm.user_id AND ub.blocked_id = $2)
+		`, roomID, senderID)
+		if err != nil {
+			return
+		}
+
+		var recipients []int
+		for rows.Next() {
+			var userID int
+			if err := rows.Scan(&userID); err == nil {
+				recipients = append(recipients, userID)
+			}
+		}
+		rows.Close()
+
+		dispatcher.notifyRecipients(recipients, roomID, content, n)
+	}()
+}
+
+// notifyRecipients 는 앱을 보고 있거나 알림을 끈 사용자를 빼고 발송 대기열에 넣는다.
+func (d *Dispatcher) notifyRecipients(recipients []int, roomID int, content string, n Notification) {
+	for _, userID := range recipients {
+		if d.isActive(userID) {
+			continue
+		}
+		if !d.shouldNotify(userID, roomID, content) {
+			continue
+		}
+		d.enqueueUser(userID, n)
+	}
+}
+
+// NotifyUser 는 채팅방과 무관한 알림(친구 요청 등)을 보낸다.
+func NotifyUser(userID int, n Notification) {
+	if dispatcher == nil || websocket.IsUserActive(userID) {
+		return
+	}
+
+	go func() {
+		if !services.ShouldNotifyUser(userID) {
+			return
+		}
+		dispatcher.enqueueUser(userID, n)
+	}()
+}
+
+func (d *Dispatcher) enqueueUser(userID int, n Notification) {
+	for _, t := range d.tokens(userID) {
+		d.enqueue(job{userID: userID, platform: t.platform, token: t.token, notification: n})
+	}
+}
+
+func loadDeviceTokens(userID int) []deviceToken {
+	rows, err := config.DB.Query(`
+		SELECT platform, token FROM push_tokens WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var tokens []deviceToken
+	for rows.Next() {
+		var t deviceToken
+		if err := rows.Scan(&t.platform, &t.token); err == nil {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+func deleteDeviceToken(token string) {
+	config.DB.Exec("DELETE FROM push_tokens WHERE token = $1", token)
+}
+
+func (d *Dispatcher) enqueue(j job) {
+	select {
+	case d.queue <- j:
+	default:
+		log.Printf("Push queue full, dropping notification for user_id=%d", j.userID)
+	}
+}
+
+func (d *Dispatcher) worker() {
+	for j := range d.queue {
+		d.deliver(j)
+	}
+}
+
+func (d *Dispatcher) deliver(j job) {
+	provider, ok := d.providers[j.platform]
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err := provider.Send(ctx, j.token, j.notification)
+	cancel()
+
+	if err == nil {
+		return
+	}
+
+	var permanent *PermanentError
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		log.Printf("Pruning dead %s push token for user_id=%d", j.platform, j.userID)
+		d.pruneToken(j.token)
+	case errors.As(err, &permanent):
+		log.Printf("Push to user_id=%d failed permanently: %v", j.userID, err)
+	default:
+		j.attempt++
+		if j.attempt >= d.maxAttempts {
+			log.Printf("Push to user_id=%d failed after %d attempts: %v", j.userID, j.attempt, err)
+			return
+		}
+		time.AfterFunc(d.backoff(j.attempt), func() { d.enqueue(j) })
+	}
+}
+
+// 지수 백오프에 최대 50%의 지터를 더한다
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.baseBackoff << uint(attempt-1)
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
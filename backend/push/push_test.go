@@ -0,0 +1,170 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestDispatcher 는 DB와 웹소켓 없이 FakeProvider 로 발송하는 디스패처를 만든다.
+// tokens 는 사용자별 FCM 토큰 목록이다.
+func newTestDispatcher(provider Provider, tokens map[int][]string) (*Dispatcher, *prunedTokens) {
+	d := NewDispatcher(map[string]Provider{PlatformFCM: provider}, 1)
+	d.baseBackoff = time.Millisecond
+	d.isActive = func(int) bool { return false }
+	d.shouldNotify = func(int, int, string) bool { return true }
+	d.tokens = func(userID int) []deviceToken {
+		var result []deviceToken
+		for _, token := range tokens[userID] {
+			result = append(result, deviceToken{platform: PlatformFCM, token: token})
+		}
+		return result
+	}
+
+	pruned := &prunedTokens{}
+	d.pruneToken = pruned.add
+	return d, pruned
+}
+
+type prunedTokens struct {
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (p *prunedTokens) add(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens = append(p.tokens, token)
+}
+
+func (p *prunedTokens) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.tokens...)
+}
+
+// countingProvider 는 발송 시도 횟수를 세고 정해진 오류를 돌려준다.
+type countingProvider struct {
+	mu       sync.Mutex
+	attempts int
+	err      error
+}
+
+func (p *countingProvider) Send(ctx context.Context, token string, n Notification) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts++
+	return p.err
+}
+
+func (p *countingProvider) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.attempts
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcherRetriesTransientFailures(t *testing.T) {
+	fake := NewFakeProvider()
+	fake.FailNext(2)
+	d, _ := newTestDispatcher(fake, map[int][]string{1: {"token-1"}})
+
+	d.enqueueUser(1, Notification{Title: "hello"})
+
+	waitFor(t, "delivery after retries", func() bool { return len(fake.Sent()) == 1 })
+	if got := fake.Sent()[0]; got.Token != "token-1" || got.Notification.Title != "hello" {
+		t.Fatalf("delivered %+v", got)
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	provider := &countingProvider{err: errors.New("unavailable")}
+	d, _ := newTestDispatcher(provider, map[int][]string{1: {"token-1"}})
+	d.maxAttempts = 3
+
+	d.enqueueUser(1, Notification{})
+
+	waitFor(t, "max attempts", func() bool { return provider.count() >= 3 })
+	time.Sleep(50 * time.Millisecond)
+	if got := provider.count(); got != 3 {
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+}
+
+func TestDispatcherDoesNotRetryPermanentErrors(t *testing.T) {
+	provider := &countingProvider{err: &PermanentError{Err: errors.New("bad request")}}
+	d, pruned := newTestDispatcher(provider, map[int][]string{1: {"token-1"}})
+
+	d.enqueueUser(1, Notification{})
+
+	waitFor(t, "first attempt", func() bool { return provider.count() >= 1 })
+	time.Sleep(50 * time.Millisecond)
+	if got := provider.count(); got != 1 {
+		t.Fatalf("attempts = %d, want 1", got)
+	}
+	if len(pruned.list()) != 0 {
+		t.Fatalf("pruned %v, want none", pruned.list())
+	}
+}
+
+func TestDispatcherPrunesInvalidTokens(t *testing.T) {
+	fake := NewFakeProvider()
+	fake.MarkInvalid("dead")
+	d, pruned := newTestDispatcher(fake, map[int][]string{1: {"dead", "alive"}})
+
+	d.enqueueUser(1, Notification{})
+
+	waitFor(t, "prune", func() bool { return len(pruned.list()) == 1 })
+	waitFor(t, "delivery to the valid token", func() bool { return len(fake.Sent()) == 1 })
+	if got := pruned.list(); got[0] != "dead" {
+		t.Fatalf("pruned %v, want [dead]", got)
+	}
+	if got := fake.Sent()[0].Token; got != "alive" {
+		t.Fatalf("delivered to %s, want alive", got)
+	}
+}
+
+func TestNotifyRecipientsSkipsActiveAndMuted(t *testing.T) {
+	fake := NewFakeProvider()
+	d, _ := newTestDispatcher(fake, map[int][]string{
+		1: {"active-user"},
+		2: {"muted-user"},
+		3: {"offline-user"},
+	})
+	d.isActive = func(userID int) bool { return userID == 1 }
+	d.shouldNotify = func(userID, roomID int, content string) bool { return userID != 2 }
+
+	d.notifyRecipients([]int{1, 2, 3}, 10, "hi", Notification{Title: "room"})
+
+	waitFor(t, "delivery", func() bool { return len(fake.Sent()) >= 1 })
+	time.Sleep(50 * time.Millisecond)
+	sent := fake.Sent()
+	if len(sent) != 1 || sent[0].Token != "offline-user" {
+		t.Fatalf("delivered %+v, want only offline-user", sent)
+	}
+}
+
+func TestBackoffGrowsExponentiallyWithJitter(t *testing.T) {
+	d := &Dispatcher{baseBackoff: 100 * time.Millisecond}
+
+	for attempt := 1; attempt <= 4; attempt++ {
+		base := d.baseBackoff << uint(attempt-1)
+		for i := 0; i < 20; i++ {
+			if got := d.backoff(attempt); got < base || got > base+base/2 {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, got, base, base+base/2)
+			}
+		}
+	}
+}
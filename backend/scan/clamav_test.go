@@ -0,0 +1,241 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// stubClamd 는 한 연결씩 handle 로 처리하는 clamd 대역을 띄우고 tcp:// 주소를 돌려준다.
+func stubClamd(t *testing.T, handle func(conn net.Conn, r *bufio.Reader, command string)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				command, err := r.ReadString(0)
+				if err != nil {
+					return
+				}
+				handle(conn, r, strings.TrimRight(command, "\x00"))
+			}()
+		}
+	}()
+	return "tcp://" + listener.Addr().String()
+}
+
+// instreamStub 은 INSTREAM 으로 받은 내용을 모아 EICAR 가 있으면 FOUND 로 응답한다.
+// maxSize 를 넘으면 clamd 처럼 오류를 보내고 쓰기를 닫는다.
+func instreamStub(maxSize int) func(net.Conn, *bufio.Reader, string) {
+	return func(conn net.Conn, r *bufio.Reader, command string) {
+		if command == "zPING" {
+			conn.Write([]byte("PONG\x00"))
+			return
+		}
+		if command != "zINSTREAM" {
+			conn.Write([]byte("UNKNOWN COMMAND\x00"))
+			return
+		}
+
+		var data []byte
+		for {
+			var length [4]byte
+			if _, err := io.ReadFull(r, length[:]); err != nil {
+				return
+			}
+			n := int(binary.BigEndian.Uint32(length[:]))
+			if n == 0 {
+				break
+			}
+			if len(data)+n > maxSize {
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				// 응답이 RST로 사라지지 않게 남은 입력을 읽어 버린다
+				conn.(*net.TCPConn).CloseWrite()
+				io.Copy(io.Discard, r)
+				return
+			}
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			data = append(data, chunk...)
+		}
+
+		if bytes.Contains(data, eicar) {
+			conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+			return
+		}
+		conn.Write([]byte("stream: OK\x00"))
+	}
+}
+
+func newTestScanner(t *testing.T, address string) *ClamdScanner {
+	t.Helper()
+	scanner, err := NewClamdScanner(address, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scanner
+}
+
+func TestClamdScan(t *testing.T) {
+	address := stubClamd(t, instreamStub(1<<20))
+	scanner := newTestScanner(t, address)
+
+	// 여러 청크에 걸친 파일의 끝부분에 EICAR 가 있어도 찾아야 한다
+	large := append(bytes.Repeat([]byte("a"), 3*clamdChunkSize+17), eicar...)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		infected  bool
+		signature string
+	}{
+		{"empty", nil, false, ""},
+		{"clean", []byte("hello"), false, ""},
+		{"clean multi-chunk", bytes.Repeat([]byte("b"), 2*clamdChunkSize+1), false, ""},
+		{"eicar", eicar, true, "Eicar-Signature"},
+		{"eicar multi-chunk", large, true, "Eicar-Signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Fatalf("result = %+v, want infected=%v signature=%q", result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestClamdScanSizeLimit(t *testing.T) {
+	address := stubClamd(t, instreamStub(1000))
+	scanner := newTestScanner(t, address)
+
+	_, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, 4*clamdChunkSize)))
+	if !errors.Is(err, ErrScanLimit) {
+		t.Fatalf("err = %v, want ErrScanLimit", err)
+	}
+}
+
+func TestClamdScanEarlyClose(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(net.Conn, *bufio.Reader, string)
+	}{
+		{"closed after command", func(net.Conn, *bufio.Reader, string) {}},
+		{"closed mid-stream", func(conn net.Conn, r *bufio.Reader, command string) {
+			io.ReadFull(r, make([]byte, 100))
+		}},
+		{"closed before reply", func(conn net.Conn, r *bufio.Reader, command string) {
+			for {
+				var length [4]byte
+				if _, err := io.ReadFull(r, length[:]); err != nil {
+					return
+				}
+				n := binary.BigEndian.Uint32(length[:])
+				if n == 0 {
+					return
+				}
+				io.CopyN(io.Discard, r, int64(n))
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := newTestScanner(t, stubClamd(t, tt.handle))
+			result, err := scanner.Scan(context.Background(), bytes.NewReader(bytes.Repeat([]byte("c"), 2*clamdChunkSize)))
+			if err == nil {
+				t.Fatalf("scan succeeded with %+v, want error", result)
+			}
+		})
+	}
+}
+
+func TestClamdPing(t *testing.T) {
+	scanner := newTestScanner(t, stubClamd(t, instreamStub(1<<20)))
+	if err := scanner.Ping(context.Background()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	wrong := newTestScanner(t, stubClamd(t, func(conn net.Conn, r *bufio.Reader, command string) {
+		conn.Write([]byte("HELLO\x00"))
+	}))
+	if err := wrong.Ping(context.Background()); err == nil {
+		t.Fatal("ping accepted unexpected reply")
+	}
+
+	// 아무도 듣지 않는 포트
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "tcp://" + listener.Addr().String()
+	listener.Close()
+	if err := newTestScanner(t, address).Ping(context.Background()); err == nil {
+		t.Fatal("ping succeeded against a closed port")
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply  string
+		result Result
+		err    error
+	}{
+		{"stream: OK", Result{}, nil},
+		{"OK", Result{}, nil},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}, nil},
+		{"INSTREAM size limit exceeded. ERROR", Result{}, ErrScanLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			result, err := parseReply(tt.reply)
+			if result != tt.result || !errors.Is(err, tt.err) {
+				t.Fatalf("parseReply = %+v, %v; want %+v, %v", result, err, tt.result, tt.err)
+			}
+		})
+	}
+
+	if _, err := parseReply("stream: Can't allocate memory ERROR"); err == nil {
+		t.Fatal("unknown reply accepted")
+	}
+}
+
+func TestNewClamdScanner(t *testing.T) {
+	for _, address := range []string{"tcp://localhost:3310", "unix:///var/run/clamav/clamd.ctl"} {
+		if _, err := NewClamdScanner(address, time.Second); err != nil {
+			t.Errorf("NewClamdScanner(%q): %v", address, err)
+		}
+	}
+	for _, address := range []string{"localhost:3310", "http://localhost:3310", "://"} {
+		if _, err := NewClamdScanner(address, time.Second); err == nil {
+			t.Errorf("NewClamdScanner(%q) accepted invalid address", address)
+		}
+	}
+}
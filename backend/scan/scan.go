@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"messenger/config"
+	"time"
+)
+
+// Result 는 검사 결과다. Infected면 Signature에 탐지된 시그니처 이름이 담긴다.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner 는 업로드 파일을 메시지로 보내기 전에 악성 코드를 검사한다.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+var Default Scanner = NoopScanner{}
+
+// Init 은 SCAN_MODE에 따라 스캐너를 고른다. 기본값 none은 검사하지 않는다.
+func Init() error {
+	cfg := config.AppConfig
+
+	switch cfg.ScanMode {
+	case "none", "":
+		Default = NoopScanner{}
+	case "clamav":
+		scanner, err := NewClamdScanner(cfg.ClamdAddress, time.Duration(cfg.ScanTimeoutSeconds)*time.Second)
+		if err != nil {
+			return err
+		}
+		// 주소가 잘못되었으면 업로드가 모두 실패하기 전에 시작 단계에서 알린다
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := scanner.Ping(ctx); err != nil {
+			return fmt.Errorf("clamd at %s is not reachable: %w", cfg.ClamdAddress, err)
+		}
+		Default = scanner
+	default:
+		return fmt.Errorf("unknown scan mode: %s", cfg.ScanMode)
+	}
+
+	return nil
+}
+
+// Enabled 는 실제 검사기가 설정되었는지 알려준다.
+// 검사하지 않은 파일을 검사 완료로 기록하지 않기 위해 쓴다.
+func Enabled() bool {
+	_, noop := Default.(NoopScanner)
+	return !noop
+}
+
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return Result{}, nil
+}
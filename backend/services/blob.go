@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"messenger/config"
+	"messenger/media"
+	"messenger/models"
+	"messenger/scan"
+	"messenger/storage"
+	"time"
+)
+
+// 참조가 0이 된 뒤 이 시간이 지나야 지운다.
+// 업로드 직후 blob을 등록하고 message_files에서 참조하기 전까지의 틈을 보호한다.
+const blobGracePeriod = time.Hour
+
+// Blob 은 SHA-256 기준으로 한 번만 저장되는 파일이다.
+// 썸네일은 StorageKey에서 파생되므로 같은 blob을 참조하는 파일끼리 함께 쓴다.
+type Blob struct {
+	SHA256     string
+	StorageKey string
+	Size       int64
+	MimeType   string
+	Image      *media.ImageInfo
+	ScanStatus string
+	Signature  string
+}
+
+// BlobHasher 는 업로드를 스토리지에 쓰는 동안 내용 해시를 함께 계산한다.
+type BlobHasher struct {
+	hash hash.Hash
+}
+
+func NewBlobHasher() *BlobHasher {
+	return &BlobHasher{hash: sha256.New()}
+}
+
+func (h *BlobHasher) Reader(r io.Reader) io.Reader {
+	return io.TeeReader(r, h.hash)
+}
+
+func (h *BlobHasher) Sum() string {
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// ClaimBlob 은 방금 key에 저장한 객체를 blob으로 등록한다.
+// 같은 내용이 이미 있으면 방금 저장한 객체를 지우고 기존 blob을 반환한다 (existed = true).
+// 반환된 blob은 호출한 쪽이 참조 컬럼(message_files.blob_sha256 등)에 넣어야 ref_count가 올라간다.
+func ClaimBlob(ctx context.Context, sha string, key string, size int64, mimeType string) (Blob, bool, error) {
+	blob := Blob{SHA256: sha}
+	var width, height sql.NullInt64
+	var blurhash, signature sql.NullString
+
+	// 기존 blob이어도 updated_at을 갱신해 참조를 걸기 전에 정리되지 않게 한다
+	err := config.DB.QueryRowContext(ctx, `
+		INSERT INTO blobs (sha256, storage_key, size, mime_type)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (sha256) DO UPDATE SET updated_at = NOW()
+		RETURNING storage_key, size, mime_type, width, height, blurhash, scan_status, scan_signature
+	`, sha, key, size, mimeType).Scan(&blob.StorageKey, &blob.Size, &blob.MimeType, &width, &height, &blurhash,
+		&blob.ScanStatus, &signature)
+	blob.Signature = signature.String
+
+	if err != nil {
+		return blob, false, err
+	}
+
+	if width.Valid && height.Valid {
+		blob.Image = &media.ImageInfo{Width: int(width.Int64), Height: int(height.Int64), Blurhash: blurhash.String}
+	}
+
+	existed := blob.StorageKey != key
+	if existed {
+		storage.Default.Delete(context.Background(), key)
+	}
+	return blob, existed, nil
+}
+
+func SetBlobImageInfo(sha string, info media.ImageInfo) error {
+	_, err := config.DB.Exec(`
+		UPDATE blobs SET width = $1, height = $2, blurhash = $3 WHERE sha256 = $4
+	`, info.Width, info.Height, info.Blurhash, sha)
+	return err
+}
+
+// ScanBlob 은 blob을 악성 코드 검사기로 검사하고 결과를 기록한다.
+// 같은 내용은 한 번만 검사하며, 검사기가 꺼져 있으면 기록하지 않아 나중에 켰을 때 다시 검사된다.
+func ScanBlob(ctx context.Context, blob *Blob) error {
+	if blob.ScanStatus != "pending" || !scan.Enabled() {
+		return nil
+	}
+
+	object, _, err := storage.Default.Open(ctx, blob.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	result, err := scan.Default.Scan(ctx, object)
+	if err != nil {
+		return err
+	}
+
+	blob.ScanStatus = "clean"
+	if result.Infected {
+		blob.ScanStatus = "infected"
+		blob.Signature = result.Signature
+		log.Printf("Quarantined blob %s: %s", blob.SHA256, result.Signature)
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE blobs SET scan_status = $1, scan_signature = NULLIF($2, ''), scanned_at = NOW() WHERE sha256 = $3
+	`, blob.ScanStatus, blob.Signature, blob.SHA256)
+	return err
+}
+
+// StartBlobCleanup 은 참조가 없는 blob을 주기적으로 스토리지에서 지운다.
+func StartBlobCleanup() {
+	go func() {
+		ticker := time.NewTicker(30 * time.Minute)
+		defer ticker.Stop()
+		for {
+			CleanupUnreferencedBlobs()
+			<-ticker.C
+		}
+	}()
+}
+
+func CleanupUnreferencedBlobs() {
+	// 조건부 DELETE로 지우므로 그 사이 다시 참조된 blob은 남는다.
+	// ref_count가 실제와 어긋나도 아직 참조 중인 blob 하나 때문에 외래 키 오류로
+	// 전체 정리가 멈추지 않도록 참조 테이블을 직접 확인한다.
+	quarantineRetention := time.Duration(config.AppConfig.QuarantineRetentionDays) * 24 * time.Hour
+	rows, err := config.DB.Query(`
+		DELETE FROM blobs b
+		WHERE b.ref_count <= 0 AND b.updated_at < $1
+		AND (b.scan_status <> 'infected' OR b.scanned_at < $2)
+		AND NOT EXISTS (SELECT 1 FROM message_files mf WHERE mf.blob_sha256 = b.sha256)
+		AND NOT EXISTS (SELECT 1 FROM users u WHERE u.profile_image_sha256 = b.sha256 OR u.background_image_sha256 = b.sha256)
+		AND NOT EXISTS (SELECT 1 FROM profile_image_history h WHERE h.blob_sha256 = b.sha256)
+		RETURNING b.storage_key
+	`, time.Now().Add(-blobGracePeriod), time.Now().Add(-quarantineRetention))
+	if err != nil {
+		log.Printf("Failed to clean up blobs: %v", err)
+		return
+	}
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if rows.Scan(&key) == nil {
+			keys = append(keys, key)
+		}
+	}
+	rows.Close()
+
+	for _, key := range keys {
+		media.DeleteWithThumbnails(context.Background(), storage.Default, key)
+	}
+	if len(keys) > 0 {
+		log.Printf("Removed %d unreferenced blobs", len(keys))
+	}
+}
+
+// GetStorageReport 는 중복 제거로 절약한 용량과 가장 많이 중복된 blob을 집계한다.
+func GetStorageReport(limit int) (models.StorageReport, error) {
+	var report models.StorageReport
+
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(size * GREATEST(ref_count, 1)), 0)
+		FROM blobs
+	`).Scan(&report.BlobCount, &report.StoredBytes, &report.LogicalBytes)
+	if err != nil {
+		return report, err
+	}
+	report.SavedBytes = report.LogicalBytes - report.StoredBytes
+
+	rows, err := config.DB.Query(`
+		SELECT sha256, size, mime_type, ref_count, size * (ref_count - 1), created_at
+		FROM blobs
+		WHERE ref_count > 1
+		ORDER BY size * (ref_count - 1) DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	report.TopDuplicates = []models.DuplicateBlob{}
+	for rows.Next() {
+		var blob models.DuplicateBlob
+		if err := rows.Scan(&blob.SHA256, &blob.Size, &blob.MimeType, &blob.RefCount, &blob.SavedBytes, &blob.CreatedAt); err != nil {
+			return report, err
+		}
+		report.TopDuplicates = append(report.TopDuplicates, blob)
+	}
+
+	return report, rows.Err()
+}
+
+// GetQuarantinedBlobs 는 격리된 blob 목록을 최근 검사 순으로 반환한다.
+func GetQuarantinedBlobs(limit int) ([]models.QuarantinedBlob, error) {
+	rows, err := config.DB.Query(`
+		SELECT sha256, size, mime_type, COALESCE(scan_signature, ''), ref_count, scanned_at
+		FROM blobs
+		WHERE scan_status = 'infected'
+		ORDER BY scanned_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blobs := []models.QuarantinedBlob{}
+	for rows.Next() {
+		var blob models.QuarantinedBlob
+		if err := rows.Scan(&blob.SHA256, &blob.Size, &blob.MimeType, &blob.Signature, &blob.RefCount, &blob.ScannedAt); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs, rows.Err()
+}
@@ -0,0 +1,24 @@
+package services
+
+import "messenger/config"
+
+// HasBlocked 는 blockerID 가 blockedID 를 차단했는지 확인한다.
+func HasBlocked(blockerID, blockedID int) bool {
+	var blocked bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2)
+	`, blockerID, blockedID).Scan(&blocked)
+	return blocked
+}
+
+// IsBlocked 는 두 사용자 중 어느 한쪽이라도 상대를 차단했는지 확인한다.
+func IsBlocked(userA, userB int) bool {
+	var blocked bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`, userA, userB).Scan(&blocked)
+	return blocked
+}
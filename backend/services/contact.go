@@ -0,0 +1,87 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"messenger/config"
+	"regexp"
+	"strings"
+)
+
+// 국가 번호 없이 저장된 번호는 한국 번호로 본다
+const defaultCountryCode = "82"
+
+var (
+	ErrContactSyncLimit = errors.New("contact sync limit exceeded")
+
+	phoneHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// NormalizePhone 은 전화번호를 +국가번호 형식(E.164)으로 맞춘다.
+// 클라이언트도 같은 규칙으로 정규화한 뒤 해시해야 연락처가 매칭된다.
+//
+//	010-1234-5678   -> +821012345678
+//	+82 10 1234 5678 -> +821012345678
+func NormalizePhone(phone string) string {
+	international := strings.HasPrefix(strings.TrimSpace(phone), "+")
+
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	number := digits.String()
+	if number == "" {
+		return ""
+	}
+
+	switch {
+	case international:
+		return "+" + number
+	case strings.HasPrefix(number, "00"):
+		return "+" + number[2:]
+	case strings.HasPrefix(number, "0"):
+		return "+" + defaultCountryCode + number[1:]
+	default:
+		return "+" + number
+	}
+}
+
+// PhoneHash 는 정규화한 번호의 SHA-256 hex 값이다.
+func PhoneHash(phone string) string {
+	sum := sha256.Sum256([]byte(NormalizePhone(phone)))
+	return hex.EncodeToString(sum[:])
+}
+
+func ValidPhoneHash(hash string) bool {
+	return phoneHashPattern.MatchString(hash)
+}
+
+// ReserveContactSyncQuota 는 하루에 새로 조회할 수 있는 번호 수를 제한해 번호 대입(enumeration)을 막는다.
+// 이미 동기화된 번호를 다시 보내는 것은 한도에 포함되지 않는다.
+// 동기화가 실패하면 사용량도 함께 되돌려지도록 동기화와 같은 트랜잭션 안에서 호출한다.
+func ReserveContactSyncQuota(tx *sql.Tx, userID, count int) error {
+	limit := config.AppConfig.ContactSyncDailyLimit
+	if count == 0 {
+		return nil
+	}
+	if count > limit {
+		return ErrContactSyncLimit
+	}
+
+	var used int
+	err := tx.QueryRow(`
+		INSERT INTO contact_sync_usage (user_id, day, hashes) VALUES ($1, CURRENT_DATE, $2)
+		ON CONFLICT (user_id, day) DO UPDATE SET hashes = contact_sync_usage.hashes + EXCLUDED.hashes
+		WHERE contact_sync_usage.hashes + EXCLUDED.hashes <= $3
+		RETURNING hashes
+	`, userID, count, limit).Scan(&used)
+
+	if err == sql.ErrNoRows {
+		return ErrContactSyncLimit
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"messenger/config"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignFileURL 은 Authorization 헤더 없이 <img>/<video> 태그에서 쓸 수 있는
+// 짧은 수명의 서명 쿼리(expires, signature)를 만든다.
+func SignFileURL(fileID string, expiresAt time.Time) url.Values {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return url.Values{
+		"expires":   {expires},
+		"signature": {fileSignature(fileID, expires)},
+	}
+}
+
+func VerifyFileSignature(fileID, expires, signature string) bool {
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresUnix {
+		return false
+	}
+
+	expected := fileSignature(fileID, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func fileSignature(fileID, expires string) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWTSecret))
+	mac.Write([]byte("file:" + fileID + ":" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"messenger/config"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 채팅방별 알림 수준
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyMuted    = "muted"
+)
+
+type RoomNotificationSetting struct {
+	Level      string     `json:"level"`
+	MutedUntil *time.Time `json:"muted_until,omitempty"`
+}
+
+type NotificationSettings struct {
+	DNDEnabled bool   `json:"dnd_enabled"`
+	DNDStart   string `json:"dnd_start"`
+	DNDEnd     string `json:"dnd_end"`
+	Timezone   string `json:"timezone"`
+}
+
+var clockPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+func DefaultNotificationSettings() NotificationSettings {
+	return NotificationSettings{
+		DNDEnabled: false,
+		DNDStart:   "23:00",
+		DNDEnd:     "07:00",
+		Timezone:   "Asia/Seoul",
+	}
+}
+
+// 만료된 음소거는 없는 것으로 취급한다
+func GetRoomNotificationSetting(userID, roomID int) RoomNotificationSetting {
+	var level string
+	var mutedUntil sql.NullTime
+
+	err := config.DB.QueryRow(`
+		SELECT level, muted_until FROM notification_mutes
+		WHERE user_id = $1 AND room_id = $2
+		AND (muted_until IS NULL OR muted_until > NOW())
+	`, userID, roomID).Scan(&level, &mutedUntil)
+
+	if err != nil {
+		return RoomNotificationSetting{Level: NotifyAll}
+	}
+
+	setting := RoomNotificationSetting{Level: level}
+	if mutedUntil.Valid {
+		setting.MutedUntil = &mutedUntil.Time
+	}
+	return setting
+}
+
+func IsRoomMuted(userID, roomID int) bool {
+	return GetRoomNotificationSetting(userID, roomID).Level == NotifyMuted
+}
+
+func SetRoomNotificationSetting(userID, roomID int, level string, mutedUntil *time.Time) error {
+	if level == NotifyAll {
+		_, err := config.DB.Exec(`
+			DELETE FROM notification_mutes WHERE user_id = $1 AND room_id = $2
+		`, userID, roomID)
+		return err
+	}
+
+	if level != NotifyMuted && level != NotifyMentions {
+		return fmt.Errorf("invalid notification level: %s", level)
+	}
+
+	_, err := config.DB.Exec(`
+		INSERT INTO notification_mutes (user_id, room_id, level, muted_until)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, room_id) DO UPDATE
+		SET level = EXCLUDED.level, muted_until = EXCLUDED.muted_until, created_at = NOW()
+	`, userID, roomID, level, mutedUntil)
+	return err
+}
+
+func GetNotificationSettings(userID int) NotificationSettings {
+	settings := DefaultNotificationSettings()
+
+	config.DB.QueryRow(`
+		SELECT dnd_enabled, to_char(dnd_start, 'HH24:MI'), to_char(dnd_end, 'HH24:MI'), timezone
+		FROM notification_settings WHERE user_id = $1
+	`, userID).Scan(&settings.DNDEnabled, &settings.DNDStart, &settings.DNDEnd, &settings.Timezone)
+
+	return settings
+}
+
+func ValidateNotificationSettings(settings NotificationSettings) error {
+	if !clockPattern.MatchString(settings.DNDStart) || !clockPattern.MatchString(settings.DNDEnd) {
+		return fmt.Errorf("dnd_start and dnd_end must be HH:MM")
+	}
+	if _, err := time.LoadLocation(settings.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone: %s", settings.Timezone)
+	}
+	return nil
+}
+
+func SaveNotificationSettings(userID int, settings NotificationSettings) error {
+	_, err := config.DB.Exec(`
+		INSERT INTO notification_settings (user_id, dnd_enabled, dnd_start, dnd_end, timezone, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET dnd_enabled = EXCLUDED.dnd_enabled, dnd_start = EXCLUDED.dnd_start,
+			dnd_end = EXCLUDED.dnd_end, timezone = EXCLUDED.timezone, updated_at = NOW()
+	`, userID, settings.DNDEnabled, settings.DNDStart, settings.DNDEnd, settings.Timezone)
+	return err
+}
+
+// 시작이 종료보다 늦으면 (예: 23:00~07:00) 자정을 넘기는 구간으로 본다
+func (s NotificationSettings) InQuietHours(now time.Time) bool {
+	if !s.DNDEnabled {
+		return false
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+
+	start, okStart := parseClock(s.DNDStart)
+	end, okEnd := parseClock(s.DNDEnd)
+	if !okStart || !okEnd || start == end {
+		return false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// ShouldNotify 는 채팅방 메시지의 푸시 알림을 보낼지 정한다 (push.NotifyRoom 에서 사용).
+// 채팅방 알림 수준, 멘션 여부, 방해 금지 시간을 차례로 확인한다.
+func ShouldNotify(recipientID, roomID int, content string) bool {
+	setting := GetRoomNotificationSetting(recipientID, roomID)
+
+	switch setting.Level {
+	case NotifyMuted:
+		return false
+	case NotifyMentions:
+		if !isMentioned(recipientID, content) {
+			return false
+		}
+	}
+
+	return !GetNotificationSettings(recipientID).InQuietHours(time.Now())
+}
+
+// 채팅방과 무관한 알림은 방해 금지 시간만 확인한다
+func ShouldNotifyUser(recipientID int) bool {
+	return !GetNotificationSettings(recipientID).InQuietHours(time.Now())
+}
+
+func isMentioned(userID int, content string) bool {
+	if content == "" || !strings.Contains(content, "@") {
+		return false
+	}
+
+	var username string
+	if err := config.DB.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&username); err != nil {
+		return false
+	}
+
+	return containsMention(content, username)
+}
+
+// containsMention 은 content 에 "@username" 이 단어로 들어 있는지 찾는다.
+// 앞뒤 글자가 영문, 숫자, _ 이면 다른 이름의 일부로 본다.
+func containsMention(content, username string) bool {
+	mention := "@" + username
+	for offset := 0; offset < len(content); {
+		i := strings.Index(content[offset:], mention)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(mention)
+
+		before, _ := utf8.DecodeLastRuneInString(content[:start])
+		after, _ := utf8.DecodeRuneInString(content[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(content) || !isWordRune(after)) {
+			return true
+		}
+		offset = start + 1
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func parseClock(value string) (int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
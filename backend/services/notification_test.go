@@ -0,0 +1,31 @@
+package services
+
+import "testing"
+
+func TestContainsMention(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"@kim", true},
+		{"hi @kim", true},
+		{"@kim, are you there?", true},
+		{"(@kim)", true},
+		{"@kim님 확인 부탁드려요", true},
+		{"안녕@kim", true},
+		{"@kimchi", false},
+		{"@kim_lee", false},
+		{"mail@kim", false},
+		{"@ki", false},
+		{"kim", false},
+		{"@kimchi and @kim", true},
+		{"@@kim", true},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := containsMention(tt.content, "kim"); got != tt.want {
+			t.Errorf("containsMention(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"messenger/config"
+)
+
+// 공개 범위. 프로필 항목, 마지막 접속 시간, 메시지·그룹 초대 허용 대상에 쓴다.
+const (
+	VisibleEveryone = "everyone"
+	VisibleFriends  = "friends"
+	VisibleNobody   = "nobody"
+)
+
+// PrivacySettings 는 다른 사용자가 나를 찾고 연락하는 방법에 대한 설정이다.
+// 검색, 친구 추가, 채팅방 생성, 접속 상태, 읽음 표시는 모두 이 파일의 함수로 판단한다.
+type PrivacySettings struct {
+	DiscoverableByPhone    bool   `json:"discoverable_by_phone"`
+	DiscoverableByUsername bool   `json:"discoverable_by_username"`
+	WhoCanMessage          string `json:"who_can_message"`
+	WhoCanAddToGroups      string `json:"who_can_add_to_groups"`
+	LastSeenVisibility     string `json:"last_seen_visibility"`
+	SendReadReceipts       bool   `json:"send_read_receipts"`
+}
+
+func DefaultPrivacySettings() PrivacySettings {
+	return PrivacySettings{
+		DiscoverableByPhone:    true,
+		DiscoverableByUsername: true,
+		WhoCanMessage:          VisibleEveryone,
+		WhoCanAddToGroups:      VisibleEveryone,
+		LastSeenVisibility:     VisibleEveryone,
+		SendReadReceipts:       true,
+	}
+}
+
+func GetPrivacySettings(userID int) PrivacySettings {
+	settings := DefaultPrivacySettings()
+
+	config.DB.QueryRow(`
+		SELECT discoverable_by_phone, discoverable_by_username, who_can_message, who_can_add_to_groups,
+			last_seen_visibility, send_read_receipts
+		FROM user_settings WHERE user_id = $1
+	`, userID).Scan(&settings.DiscoverableByPhone, &settings.DiscoverableByUsername, &settings.WhoCanMessage,
+		&settings.WhoCanAddToGroups, &settings.LastSeenVisibility, &settings.SendReadReceipts)
+
+	return settings
+}
+
+func ValidatePrivacySettings(settings PrivacySettings) error {
+	if settings.WhoCanMessage != VisibleEveryone && settings.WhoCanMessage != VisibleFriends {
+		return fmt.Errorf("who_can_message must be everyone or friends")
+	}
+	if !ValidVisibility(settings.WhoCanAddToGroups) {
+		return fmt.Errorf("who_can_add_to_groups must be one of everyone, friends, nobody")
+	}
+	if !ValidVisibility(settings.LastSeenVisibility) {
+		return fmt.Errorf("last_seen_visibility must be one of everyone, friends, nobody")
+	}
+	return nil
+}
+
+func SavePrivacySettings(userID int, settings PrivacySettings) error {
+	_, err := config.DB.Exec(`
+		INSERT INTO user_settings (user_id, discoverable_by_phone, discoverable_by_username, who_can_message,
+			who_can_add_to_groups, last_seen_visibility, send_read_receipts, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET discoverable_by_phone = EXCLUDED.discoverable_by_phone,
+			discoverable_by_username = EXCLUDED.discoverable_by_username,
+			who_can_message = EXCLUDED.who_can_message,
+			who_can_add_to_groups = EXCLUDED.who_can_add_to_groups,
+			last_seen_visibility = EXCLUDED.last_seen_visibility,
+			send_read_receipts = EXCLUDED.send_read_receipts,
+			updated_at = NOW()
+	`, userID, settings.DiscoverableByPhone, settings.DiscoverableByUsername, settings.WhoCanMessage,
+		settings.WhoCanAddToGroups, settings.LastSeenVisibility, settings.SendReadReceipts)
+	return err
+}
+
+func ValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibleEveryone, VisibleFriends, VisibleNobody:
+		return true
+	}
+	return false
+}
+
+// CanView 는 viewerID 가 ownerID 의 visibility 범위로 공개된 정보를 볼 수 있는지 판단한다.
+// 본인은 항상 볼 수 있고, 차단 관계이거나 탈퇴 유예 중인 사용자는 공개 범위와 관계없이 볼 수 없다.
+func CanView(viewerID, ownerID int, visibility string) bool {
+	if viewerID == ownerID {
+		return true
+	}
+	if IsBlocked(viewerID, ownerID) || IsDeleted(ownerID) {
+		return false
+	}
+
+	switch visibility {
+	case VisibleEveryone:
+		return true
+	case VisibleFriends:
+		return IsFriend(ownerID, viewerID)
+	}
+	return false
+}
+
+// CanMessage 는 senderID 가 recipientID 와 새 1:1 채팅을 시작할 수 있는지 판단한다.
+func CanMessage(senderID, recipientID int) bool {
+	return CanView(senderID, recipientID, GetPrivacySettings(recipientID).WhoCanMessage)
+}
+
+// CanAddToGroup 은 adderID 가 userID 를 그룹 채팅방에 초대할 수 있는지 판단한다.
+func CanAddToGroup(adderID, userID int) bool {
+	return CanView(adderID, userID, GetPrivacySettings(userID).WhoCanAddToGroups)
+}
+
+func CanSeeLastSeen(viewerID, userID int) bool {
+	return CanView(viewerID, userID, GetPrivacySettings(userID).LastSeenVisibility)
+}
+
+func SendsReadReceipts(userID int) bool {
+	return GetPrivacySettings(userID).SendReadReceipts
+}
+
+// IsFriend 는 userID 가 friendID 를 친구로 두고 있는지 확인한다.
+func IsFriend(userID, friendID int) bool {
+	var exists bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM friends WHERE user_id = $1 AND friend_id = $2)
+	`, userID, friendID).Scan(&exists)
+	return exists
+}
+
+// IsDeleted 는 userID 가 탈퇴를 요청해 유예 기간 중인지(또는 이미 지워졌는지) 확인한다.
+func IsDeleted(userID int) bool {
+	var active bool
+	config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", userID).Scan(&active)
+	return !active
+}
+
+type FoundUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// FindUsers 는 아이디 또는 전화번호가 정확히 일치하는 사용자를 찾는다.
+// 상대의 검색 허용 설정과 차단 관계를 반영하며, 찾을 수 없는 사용자는 없는 것과 같이 취급한다.
+func FindUsers(viewerID int, query string, limit int) ([]FoundUser, error) {
+	// 번호 형식이 달라도(010-..., +8210...) 같은 번호면 찾는다
+	var phoneHash sql.NullString
+	if NormalizePhone(query) != "" {
+		phoneHash = sql.NullString{String: PhoneHash(query), Valid: true}
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT u.id, u.username, u.name FROM users u
+		WHERE u.id != $2
+		AND ((u.username = $1 AND `+discoverableCondition("u", "discoverable_by_username")+`)
+			OR ((u.phone = $1 OR u.phone_hash = $3) AND `+DiscoverableByPhoneCondition("u")+`))
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks ub
+			WHERE (ub.blocker_id = $2 AND ub.blocked_id = u.id) OR (ub.blocker_id = u.id AND ub.blocked_id = $2)
+		)
+		ORDER BY u.username = $1 DESC, u.id
+		LIMIT $4
+	`, query, viewerID, phoneHash, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []FoundUser{}
+	for rows.Next() {
+		var u FoundUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.Name); err == nil {
+			users = append(users, u)
+		}
+	}
+	return users, rows.Err()
+}
+
+// DiscoverableByPhoneCondition 은 userAlias 사용자를 전화번호(또는 연락처 동기화)로 찾을 수 있는지의 SQL 조건이다.
+func DiscoverableByPhoneCondition(userAlias string) string {
+	return discoverableCondition(userAlias, "discoverable_by_phone")
+}
+
+// ReadReceiptsCondition 은 userColumn 사용자가 읽음 표시를 보내는지의 SQL 조건이다.
+func ReadReceiptsCondition(userColumn string) string {
+	return `NOT EXISTS (SELECT 1 FROM user_settings us WHERE us.user_id = ` + userColumn + ` AND NOT us.send_read_receipts)`
+}
+
+// 설정 행이 없으면 기본값(허용)으로 본다. 탈퇴 유예 중인 사용자는 찾을 수 없다
+func discoverableCondition(userAlias, column string) string {
+	return userAlias + `.deleted_at IS NULL AND NOT EXISTS (SELECT 1 FROM user_settings us WHERE us.user_id = ` + userAlias + `.id AND NOT us.` + column + `)`
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"messenger/config"
+)
+
+// UserSettings 는 알림 외의 사용자별 설정이다.
+type UserSettings struct {
+	AutoAcceptFriendRequests  bool   `json:"auto_accept_friend_requests"`
+	StatusMessageVisibility   string `json:"status_message_visibility"`
+	ProfileImageVisibility    string `json:"profile_image_visibility"`
+	BackgroundImageVisibility string `json:"background_image_visibility"`
+	ProfileHistoryVisibility  string `json:"profile_history_visibility"`
+}
+
+func DefaultUserSettings() UserSettings {
+	return UserSettings{
+		AutoAcceptFriendRequests:  false,
+		StatusMessageVisibility:   VisibleEveryone,
+		ProfileImageVisibility:    VisibleEveryone,
+		BackgroundImageVisibility: VisibleEveryone,
+		ProfileHistoryVisibility:  VisibleNobody,
+	}
+}
+
+func GetUserSettings(userID int) UserSettings {
+	settings := DefaultUserSettings()
+
+	config.DB.QueryRow(`
+		SELECT auto_accept_friend_requests,
+			status_message_visibility, profile_image_visibility, background_image_visibility, profile_history_visibility
+		FROM user_settings WHERE user_id = $1
+	`, userID).Scan(&settings.AutoAcceptFriendRequests,
+		&settings.StatusMessageVisibility, &settings.ProfileImageVisibility,
+		&settings.BackgroundImageVisibility, &settings.ProfileHistoryVisibility)
+
+	return settings
+}
+
+func ValidateUserSettings(settings UserSettings) error {
+	for _, visibility := range []string{
+		settings.StatusMessageVisibility,
+		settings.ProfileImageVisibility,
+		settings.BackgroundImageVisibility,
+		settings.ProfileHistoryVisibility,
+	} {
+		if !ValidVisibility(visibility) {
+			return fmt.Errorf("visibility must be one of everyone, friends, nobody: %s", visibility)
+		}
+	}
+	return nil
+}
+
+func SaveUserSettings(userID int, settings UserSettings) error {
+	_, err := config.DB.Exec(`
+		INSERT INTO user_settings (user_id, auto_accept_friend_requests,
+			status_message_visibility, profile_image_visibility, background_image_visibility, profile_history_visibility, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET auto_accept_friend_requests = EXCLUDED.auto_accept_friend_requests,
+			status_message_visibility = EXCLUDED.status_message_visibility,
+			profile_image_visibility = EXCLUDED.profile_image_visibility,
+			background_image_visibility = EXCLUDED.background_image_visibility,
+			profile_history_visibility = EXCLUDED.profile_history_visibility,
+			updated_at = NOW()
+	`, userID, settings.AutoAcceptFriendRequests,
+		settings.StatusMessageVisibility, settings.ProfileImageVisibility,
+		settings.BackgroundImageVisibility, settings.ProfileHistoryVisibility)
+	return err
+}
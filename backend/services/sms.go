@@ -1,19 +1,24 @@
 package services
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
+	"math/big"
 	"messenger/config"
 	"time"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
+// GenerateVerificationCode 는 6자리 인증 코드를 만든다.
+// 비밀번호 재설정에도 쓰이므로 예측할 수 없도록 crypto/rand 를 쓴다.
 func GenerateVerificationCode() string {
-	return fmt.Sprintf("%06d", rand.Intn(1000000))
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return fmt.Sprintf("%06d", n.Int64())
 }
 
 func SendSMS(phone, code string) error {
@@ -27,14 +32,43 @@ func SendSMS(phone, code string) error {
 	return nil
 }
 
+var ErrSMSNotConfigured = errors.New("sms: no provider configured for notices")
+
+// SendNotice 는 인증 코드가 아닌 안내 문자를 보낸다.
+// 발송 경로가 없는 모드에서는 보낸 척하지 않고 ErrSMSNotConfigured 를 돌려준다.
+func SendNotice(phone, message string) error {
+	if config.AppConfig.SMSMode == "mock" {
+		log.Printf("[MOCK SMS] Phone: %s, Message: %s", phone, message)
+		return nil
+	}
+
+	// TODO: SendSMS 와 같은 발송 경로 사용
+	return fmt.Errorf("%w (SMS_MODE=%s)", ErrSMSNotConfigured, config.AppConfig.SMSMode)
+}
+
+// SaveVerificationCode 는 새 코드를 저장하고 같은 번호로 보낸 이전 코드는 만료시킨다.
+// 살아 있는 코드가 여러 개면 그만큼 추측이 쉬워진다.
 func SaveVerificationCode(phone, code string) error {
 	expiresAt := time.Now().Add(5 * time.Minute)
 
-	_, err := config.DB.Exec(`
-		INSERT INTO phone_verifications (phone, code, expires_at)
-		VALUES ($1, $2, $3)
-	`, phone, code, expiresAt)
-
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE phone_verifications SET expires_at = NOW() WHERE phone = $1 AND expires_at > NOW()
+	`, phone)
+	if err == nil {
+		_, err = tx.Exec(`
+			INSERT INTO phone_verifications (phone, code, expires_at)
+			VALUES ($1, $2, $3)
+		`, phone, code, expiresAt)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	return err
 }
 
@@ -64,6 +98,15 @@ func VerifyCode(phone, code string) (bool, error) {
 	return err == nil, err
 }
 
+// ConsumeVerificationCode 는 확인한 코드를 만료시켜 다시 쓸 수 없게 한다.
+func ConsumeVerificationCode(phone, code string) error {
+	_, err := config.DB.Exec(`
+		UPDATE phone_verifications SET expires_at = NOW()
+		WHERE phone = $1 AND code = $2 AND expires_at > NOW()
+	`, phone, code)
+	return err
+}
+
 func IsPhoneVerified(phone string) bool {
 	var verified bool
 	err := config.DB.QueryRow(`
@@ -75,3 +118,63 @@ func IsPhoneVerified(phone string) bool {
 
 	return err == nil && verified
 }
+
+// 인증 코드 발송과 확인 실패 한도. 6자리 코드를 무차별 대입하지 못하게 번호와 IP 기준으로 센다.
+const (
+	smsSendWindowMinutes   = 60
+	maxSMSSendsPerPhone    = 5
+	maxSMSSendsPerIP       = 20
+	verifyWindowMinutes    = 15
+	maxVerifyFailsPerPhone = 5
+	maxVerifyFailsPerIP    = 20
+)
+
+var ErrTooManyAttempts = errors.New("too many verification attempts")
+
+// ReserveSMSSend 는 발송 한도를 확인하고 발송 한 건을 기록한다. 한도를 넘으면 ErrTooManyAttempts.
+func ReserveSMSSend(phone, ip string) error {
+	if exceeded, err := attemptsExceeded("send", phone, ip, smsSendWindowMinutes, maxSMSSendsPerPhone, maxSMSSendsPerIP); err != nil {
+		return err
+	} else if exceeded {
+		return ErrTooManyAttempts
+	}
+	return recordAttempt("send", phone, ip)
+}
+
+// VerifyCodeAttempt 는 VerifyCode 에 실패 횟수 제한을 더한다.
+// 최근 실패가 한도를 넘은 번호나 IP는 코드가 맞더라도 잠긴 동안 ErrTooManyAttempts 를 돌려준다.
+func VerifyCodeAttempt(phone, code, ip string) (bool, error) {
+	if exceeded, err := attemptsExceeded("verify_failed", phone, ip, verifyWindowMinutes, maxVerifyFailsPerPhone, maxVerifyFailsPerIP); err != nil {
+		return false, err
+	} else if exceeded {
+		return false, ErrTooManyAttempts
+	}
+
+	verified, err := VerifyCode(phone, code)
+	if err == sql.ErrNoRows {
+		if err := recordAttempt("verify_failed", phone, ip); err != nil {
+			return false, err
+		}
+	}
+	return verified, err
+}
+
+func attemptsExceeded(kind, phone, ip string, windowMinutes, maxPerPhone, maxPerIP int) (bool, error) {
+	var byPhone, byIP int
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE phone = $2), COUNT(*) FILTER (WHERE ip = $3)
+		FROM verification_attempts
+		WHERE kind = $1 AND (phone = $2 OR ip = $3) AND created_at > NOW() - make_interval(mins => $4)
+	`, kind, phone, ip, windowMinutes).Scan(&byPhone, &byIP)
+	if err != nil {
+		return false, err
+	}
+	return byPhone >= maxPerPhone || byIP >= maxPerIP, nil
+}
+
+func recordAttempt(kind, phone, ip string) error {
+	_, err := config.DB.Exec(`
+		INSERT INTO verification_attempts (kind, phone, ip) VALUES ($1, $2, $3)
+	`, kind, phone, ip)
+	return err
+}
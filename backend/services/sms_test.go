@@ -0,0 +1,22 @@
+package services
+
+import "testing"
+
+func TestGenerateVerificationCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code := GenerateVerificationCode()
+		if len(code) != 6 {
+			t.Fatalf("code %q is not 6 digits", code)
+		}
+		for _, r := range code {
+			if r < '0' || r > '9' {
+				t.Fatalf("code %q is not numeric", code)
+			}
+		}
+		seen[code] = true
+	}
+	if len(seen) < 90 {
+		t.Fatalf("only %d distinct codes out of 100", len(seen))
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := "files/2024/05/photo.jpg"
+	data := testData(4096)
+
+	written, err := s.Put(ctx, key, bytes.NewReader([]byte("old contents")), "image/jpeg")
+	if err != nil || written != 12 {
+		t.Fatalf("put = %d, %v", written, err)
+	}
+	// 같은 키에 다시 쓰면 덮어쓴다
+	if written, err = s.Put(ctx, key, bytes.NewReader(data), "image/jpeg"); err != nil || written != int64(len(data)) {
+		t.Fatalf("overwrite = %d, %v", written, err)
+	}
+
+	object, info, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if info.Size != int64(len(data)) || info.ContentType != "image/jpeg" || info.ModTime.IsZero() {
+		t.Fatalf("info = %+v", info)
+	}
+	got, err := io.ReadAll(object)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read back %d bytes (err %v), want %d", len(got), err, len(data))
+	}
+	if _, err := object.Seek(100, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 10)
+	if _, err := io.ReadFull(object, chunk); err != nil || !bytes.Equal(chunk, data[100:110]) {
+		t.Fatalf("read after seek = %v (err %v)", chunk, err)
+	}
+	object.Close()
+
+	// 임시 파일이 남지 않아야 한다
+	entries, _ := os.ReadDir(filepath.Join(root, "files", "2024", "05"))
+	if len(entries) != 1 {
+		t.Fatalf("directory has %d entries, want 1", len(entries))
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("open after delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("second delete: %v", err)
+	}
+}
+
+// 실패한 업로드는 기존 파일을 건드리지 않는다
+func TestLocalPutFailureKeepsExisting(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := s.Put(ctx, "a/b", bytes.NewReader([]byte("kept")), ""); err != nil {
+		t.Fatal(err)
+	}
+	broken := io.MultiReader(bytes.NewReader([]byte("partial")), errReader{})
+	if _, err := s.Put(ctx, "a/b", broken, ""); err == nil {
+		t.Fatal("put succeeded with a failing reader")
+	}
+
+	object, _, err := s.Open(ctx, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer object.Close()
+	if got, _ := io.ReadAll(object); string(got) != "kept" {
+		t.Fatalf("contents = %q, want kept", got)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestLocalRejectsInvalidKeys(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, key := range []string{"", "/", "../outside", "files/../../outside", "files/.."} {
+		if _, err := s.Put(ctx, key, bytes.NewReader(nil), ""); err == nil {
+			t.Errorf("Put(%q) accepted", key)
+		}
+		if _, _, err := s.Open(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) = %v, want invalid key error", key, err)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) accepted", key)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"messenger/config"
+	"time"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage 는 업로드 파일을 저장하는 백엔드다.
+// Put은 r을 끝까지 스트리밍하며 전체를 메모리에 올리지 않는다.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var Default Storage
+
+func Init() error {
+	cfg := config.AppConfig
+
+	switch cfg.StorageDriver {
+	case "local":
+		local, err := NewLocalStorage(cfg.StorageLocalDir)
+		if err != nil {
+			return err
+		}
+		Default = local
+	case "s3":
+		Default = NewS3Storage(S3Config{
+			Endpoint:     cfg.S3Endpoint,
+			Region:       cfg.S3Region,
+			Bucket:       cfg.S3Bucket,
+			AccessKey:    cfg.S3AccessKey,
+			SecretKey:    cfg.S3SecretKey,
+			UsePathStyle: cfg.S3UsePathStyle,
+		})
+	default:
+		return fmt.Errorf("unknown storage driver: %s", cfg.StorageDriver)
+	}
+
+	return nil
+}
+
+// NewKey 는 prefix 아래에 추측할 수 없는 객체 키를 만든다 (예: files/2024/05/3f9a...).
+func NewKey(prefix string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%s/%s/%s", prefix, time.Now().Format("2006/01"), hex.EncodeToString(b))
+}
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"database/sql"
 	"encoding/json"
 	"log"
 	"messenger/config"
@@ -22,9 +23,10 @@ var upgrader = websocket.Upgrader{
 }
 
 type Client struct {
-	UserID int
-	Conn   *websocket.Conn
-	Send   chan []byte
+	UserID     int
+	Conn       *websocket.Conn
+	Send       chan []byte
+	Background bool
 }
 
 type Hub struct {
@@ -53,6 +55,7 @@ func (h *Hub) run() {
 			h.mutex.Lock()
 			h.clients[client.UserID] = client
 			h.mutex.Unlock()
+			go touchLastSeen(client.UserID)
 			log.Printf("Client connected: user_id=%d", client.UserID)
 
 		case client := <-h.unregister:
@@ -62,6 +65,7 @@ func (h *Hub) run() {
 				close(client.Send)
 			}
 			h.mutex.Unlock()
+			go touchLastSeen(client.UserID)
 			log.Printf("Client disconnected: user_id=%d", client.UserID)
 
 		case message := <-h.broadcast:
@@ -121,6 +125,13 @@ func (c *Client) readPump() {
 		case "ping":
 			response, _ := json.Marshal(models.WebSocketMessage{Type: "pong"})
 			c.Send <- response
+		case "app_state":
+			// 앱이 백그라운드로 가면 푸시 알림 대상이 된다
+			if payload, ok := wsMsg.Payload.(map[string]interface{}); ok {
+				hub.mutex.Lock()
+				c.Background = payload["state"] == "background"
+				hub.mutex.Unlock()
+			}
 		}
 	}
 }
@@ -146,6 +157,26 @@ func BroadcastToRoom(roomID int, message models.WebSocketMessage) {
 	}
 	defer rows.Close()
 
+	sendToRows(rows, message)
+}
+
+// BroadcastMessageToRoom 은 발신자를 차단한 멤버를 빼고 보낸다. 발신자 본인은 차단 여부와 관계없이 받는다.
+func BroadcastMessageToRoom(roomID, senderID int, message models.WebSocketMessage) {
+	rows, err := config.DB.Query(`
+		SELECT user_id FROM chat_room_members crm
+		WHERE room_id = $1 AND left_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM user_blocks ub WHERE ub.blocker_id = crm.user_id AND ub.blocked_id = $2)
+	`, roomID, senderID)
+
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	sendToRows(rows, message)
+}
+
+func sendToRows(rows *sql.Rows, message models.WebSocketMessage) {
 	msgBytes, _ := json.Marshal(message)
 
 	hub.mutex.RLock()
@@ -177,3 +208,36 @@ func BroadcastToUser(userID int, message models.WebSocketMessage) {
 		}
 	}
 }
+
+// 연결되어 있고 앱이 포그라운드일 때만 활성 사용자로 본다
+func IsUserActive(userID int) bool {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+
+	client, ok := hub.clients[userID]
+	return ok && !client.Background
+}
+
+func IsUserOnline(userID int) bool {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+
+	_, ok := hub.clients[userID]
+	return ok
+}
+
+// 마지막 접속 시간은 연결과 연결 해제 시점에 기록한다. 공개 여부는 services.CanSeeLastSeen 이 판단한다.
+func touchLastSeen(userID int) {
+	config.DB.Exec("UPDATE users SET last_seen_at = NOW() WHERE id = $1", userID)
+}
+
+// DisconnectUser 는 사용자의 웹소켓 연결을 끊는다. 세션을 무효화할 때 사용한다.
+func DisconnectUser(userID int) {
+	hub.mutex.RLock()
+	client, ok := hub.clients[userID]
+	hub.mutex.RUnlock()
+
+	if ok {
+		client.Conn.Close()
+	}
+}
@@ -7,8 +7,24 @@ CREATE TABLE users (
     phone VARCHAR(20) UNIQUE NOT NULL,
     password_hash VARCHAR(255) NOT NULL,
     name VARCHAR(100) NOT NULL,
-    profile_image BYTEA,
+    profile_image BYTEA,                       -- 이전 버전 호환용, 새 업로드는 스토리지에 저장
+    profile_image_key VARCHAR(255),
     profile_image_mime VARCHAR(50),
+    profile_image_width INTEGER,
+    profile_image_height INTEGER,
+    profile_image_sha256 CHAR(64),             -- blobs 참조
+    status_message VARCHAR(200),
+    background_image_key VARCHAR(255),
+    background_image_sha256 CHAR(64),          -- blobs 참조
+    background_image_mime VARCHAR(50),
+    background_image_width INTEGER,
+    background_image_height INTEGER,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    last_seen_at TIMESTAMP,
+    username_changed_at TIMESTAMP,
+    token_version INTEGER NOT NULL DEFAULT 0,  -- 올리면 이전에 발급한 JWT가 모두 무효
+    phone_hash CHAR(64),                       -- 정규화한 번호의 SHA-256, 연락처 동기화용
+    deleted_at TIMESTAMP,                      -- 탈퇴 요청 시간. 유예 기간이 지나면 행을 지우고 보낸 메시지는 익명으로 남는다
     created_at TIMESTAMP DEFAULT NOW(),
     updated_at TIMESTAMP DEFAULT NOW()
 );
@@ -28,6 +44,9 @@ CREATE TABLE friends (
     id SERIAL PRIMARY KEY,
     user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
     friend_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    nickname VARCHAR(100),                     -- 나에게만 보이는 친구 이름
+    favorited_at TIMESTAMP,
+    hidden_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT NOW(),
     UNIQUE(user_id, friend_id)
 );
@@ -48,6 +67,10 @@ CREATE TABLE chat_room_members (
     joined_at TIMESTAMP DEFAULT NOW(),
     left_at TIMESTAMP,
     last_read_message_id INTEGER DEFAULT 0,
+    role VARCHAR(10) NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+    pinned_at TIMESTAMP,                       -- 상단 고정 시간 (NULL이면 고정 안 함)
+    archived_at TIMESTAMP,                     -- 보관 시간 (NULL이면 보관 안 함)
+    folder_id INTEGER,
     UNIQUE(room_id, user_id)
 );
 
@@ -57,18 +80,26 @@ CREATE TABLE messages (
     room_id INTEGER REFERENCES chat_rooms(id) ON DELETE CASCADE,
     sender_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
     content TEXT,
-    type VARCHAR(10) NOT NULL CHECK (type IN ('text', 'image', 'video')),
+    type VARCHAR(10) NOT NULL CHECK (type IN ('text', 'image', 'video', 'file', 'audio', 'voice')),
     created_at TIMESTAMP DEFAULT NOW()
 );
 
 -- 7. message_files (미디어 파일)
 CREATE TABLE message_files (
     id SERIAL PRIMARY KEY,
+    public_id UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(),  -- 외부에 노출되는 파일 ID
     message_id INTEGER REFERENCES messages(id) ON DELETE CASCADE,
     filename VARCHAR(255) NOT NULL,
     mime_type VARCHAR(100) NOT NULL,
-    file_data BYTEA NOT NULL,
-    file_size INTEGER NOT NULL,
+    file_data BYTEA,                           -- 이전 버전 호환용, 새 업로드는 스토리지에 저장
+    storage_key VARCHAR(255),
+    blob_sha256 CHAR(64),                      -- blobs 참조 (중복 제거 이전 파일은 NULL)
+    file_size BIGINT NOT NULL,
+    width INTEGER,                             -- 이미지 원본 크기
+    height INTEGER,
+    blurhash VARCHAR(64),                      -- 로딩 전 표시할 플레이스홀더
+    duration_ms INTEGER,                       -- 음성/오디오 길이
+    waveform BYTEA,                            -- 음성 메시지 파형 요약 (0~255 진폭 값)
     created_at TIMESTAMP DEFAULT NOW()
 );
 
@@ -77,10 +108,268 @@ CREATE TABLE notification_mutes (
     id SERIAL PRIMARY KEY,
     user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
     room_id INTEGER REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    level VARCHAR(10) NOT NULL DEFAULT 'muted' CHECK (level IN ('muted', 'mentions')),
+    muted_until TIMESTAMP,                     -- NULL이면 해제할 때까지 유지
     created_at TIMESTAMP DEFAULT NOW(),
     UNIQUE(user_id, room_id)
 );
 
+-- 9. pinned_messages (고정 메시지)
+CREATE TABLE pinned_messages (
+    id SERIAL PRIMARY KEY,
+    room_id INTEGER REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    message_id INTEGER REFERENCES messages(id) ON DELETE CASCADE,
+    pinned_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    pinned_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(room_id, message_id)
+);
+
+-- 10. room_announcements (채팅방 공지, 방당 1개)
+CREATE TABLE room_announcements (
+    id SERIAL PRIMARY KEY,
+    room_id INTEGER UNIQUE REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- 11. announcement_dismissals (사용자별 공지 닫기)
+CREATE TABLE announcement_dismissals (
+    id SERIAL PRIMARY KEY,
+    announcement_id INTEGER REFERENCES room_announcements(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    dismissed_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(announcement_id, user_id)
+);
+
+-- 12. chat_folders (사용자별 채팅방 폴더)
+CREATE TABLE chat_folders (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(user_id, name)
+);
+
+ALTER TABLE chat_room_members
+    ADD CONSTRAINT fk_chat_room_members_folder
+    FOREIGN KEY (folder_id) REFERENCES chat_folders(id) ON DELETE SET NULL;
+
+-- 13. notification_settings (방해 금지 시간)
+CREATE TABLE notification_settings (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    dnd_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    dnd_start TIME NOT NULL DEFAULT '23:00',
+    dnd_end TIME NOT NULL DEFAULT '07:00',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Seoul',
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- 14. push_tokens (푸시 알림 기기 토큰)
+CREATE TABLE push_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    platform VARCHAR(10) NOT NULL CHECK (platform IN ('fcm', 'apns')),
+    token VARCHAR(512) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- 15. uploads (tus 이어받기 업로드, 완료 전 청크는 임시 디렉터리에 저장)
+CREATE TABLE uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    room_id INTEGER NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    upload_length BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- 16. blobs (내용 해시 기준으로 한 번만 저장하는 파일)
+-- ref_count는 트리거가 message_files, users, profile_image_history의 참조 변화에 맞춰 관리한다.
+-- 참조가 0이 된 blob은 서버의 정리 작업이 유예 시간 뒤 스토리지와 함께 지운다.
+-- 단, 격리된(infected) blob은 관리자가 확인할 수 있도록 보존 기간 동안 남긴다.
+CREATE TABLE blobs (
+    sha256 CHAR(64) PRIMARY KEY,
+    storage_key VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    blurhash VARCHAR(64),
+    ref_count INTEGER NOT NULL DEFAULT 0,
+    -- 악성 코드 검사 결과. infected는 격리 상태로, 내려주지 않고 보존 기간 뒤 정리된다.
+    scan_status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (scan_status IN ('pending', 'clean', 'infected')),
+    scan_signature VARCHAR(255),
+    scanned_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+ALTER TABLE message_files
+    ADD CONSTRAINT fk_message_files_blob
+    FOREIGN KEY (blob_sha256) REFERENCES blobs(sha256);
+
+ALTER TABLE users
+    ADD CONSTRAINT fk_users_profile_image_blob
+    FOREIGN KEY (profile_image_sha256) REFERENCES blobs(sha256);
+
+ALTER TABLE users
+    ADD CONSTRAINT fk_users_background_image_blob
+    FOREIGN KEY (background_image_sha256) REFERENCES blobs(sha256);
+
+-- TG_ARGV[0]: blob을 참조하는 컬럼 이름
+-- 트리거는 UPDATE OF <컬럼>으로 걸려 있어 SET 절에 그 컬럼이 있을 때만 실행되고,
+-- 값이 그대로면(OLD = NEW) 아무것도 바꾸지 않는다. 다른 컬럼만 바꾸는 UPDATE는 ref_count에 영향이 없다.
+-- 정리 작업은 ref_count가 어긋나는 경우에 대비해 삭제 전에 참조 테이블을 다시 확인한다.
+CREATE OR REPLACE FUNCTION adjust_blob_ref_count() RETURNS TRIGGER AS $$
+DECLARE
+    old_sha TEXT;
+    new_sha TEXT;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_sha := to_jsonb(OLD) ->> TG_ARGV[0];
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_sha := to_jsonb(NEW) ->> TG_ARGV[0];
+    END IF;
+
+    IF old_sha IS DISTINCT FROM new_sha THEN
+        IF old_sha IS NOT NULL THEN
+            UPDATE blobs SET ref_count = ref_count - 1, updated_at = NOW() WHERE sha256 = old_sha;
+        END IF;
+        IF new_sha IS NOT NULL THEN
+            UPDATE blobs SET ref_count = ref_count + 1, updated_at = NOW() WHERE sha256 = new_sha;
+        END IF;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER message_files_blob_ref_count
+    AFTER INSERT OR UPDATE OF blob_sha256 OR DELETE ON message_files
+    FOR EACH ROW EXECUTE FUNCTION adjust_blob_ref_count('blob_sha256');
+
+CREATE TRIGGER users_profile_image_blob_ref_count
+    AFTER INSERT OR UPDATE OF profile_image_sha256 OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION adjust_blob_ref_count('profile_image_sha256');
+
+CREATE TRIGGER users_background_image_blob_ref_count
+    AFTER INSERT OR UPDATE OF background_image_sha256 OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION adjust_blob_ref_count('background_image_sha256');
+
+-- 17. friend_requests (친구 요청, 수락하면 양방향 friends 행이 생긴다)
+CREATE TABLE friend_requests (
+    id SERIAL PRIMARY KEY,
+    requester_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'canceled')),
+    created_at TIMESTAMP DEFAULT NOW(),
+    responded_at TIMESTAMP,
+    CHECK (requester_id <> recipient_id)
+);
+
+-- 18. user_settings (알림 외 사용자 설정)
+CREATE TABLE user_settings (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    auto_accept_friend_requests BOOLEAN NOT NULL DEFAULT FALSE,
+    discoverable_by_phone BOOLEAN NOT NULL DEFAULT TRUE,
+    -- 프로필 항목별 공개 범위
+    status_message_visibility VARCHAR(10) NOT NULL DEFAULT 'everyone' CHECK (status_message_visibility IN ('everyone', 'friends', 'nobody')),
+    profile_image_visibility VARCHAR(10) NOT NULL DEFAULT 'everyone' CHECK (profile_image_visibility IN ('everyone', 'friends', 'nobody')),
+    background_image_visibility VARCHAR(10) NOT NULL DEFAULT 'everyone' CHECK (background_image_visibility IN ('everyone', 'friends', 'nobody')),
+    profile_history_visibility VARCHAR(10) NOT NULL DEFAULT 'nobody' CHECK (profile_history_visibility IN ('everyone', 'friends', 'nobody')),
+    -- 개인정보 보호 설정 (services/privacy.go)
+    discoverable_by_username BOOLEAN NOT NULL DEFAULT TRUE,
+    who_can_message VARCHAR(10) NOT NULL DEFAULT 'everyone' CHECK (who_can_message IN ('everyone', 'friends')),
+    who_can_add_to_groups VARCHAR(10) NOT NULL DEFAULT 'everyone' CHECK (who_can_add_to_groups IN ('everyone', 'friends', 'nobody')),
+    last_seen_visibility VARCHAR(10) NOT NULL DEFAULT 'everyone' CHECK (last_seen_visibility IN ('everyone', 'friends', 'nobody')),
+    send_read_receipts BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- 19. user_blocks (차단. 차단 이후 상대가 보낸 메시지는 나에게 전달되지 않는다)
+CREATE TABLE user_blocks (
+    blocker_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+-- 20. user_contacts (동기화한 주소록. 번호 원문 대신 해시만 저장)
+CREATE TABLE user_contacts (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    phone_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, phone_hash)
+);
+
+-- 21. contact_sync_usage (연락처 동기화 일일 한도)
+CREATE TABLE contact_sync_usage (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    hashes INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+-- 22. friend_recommendation_dismissals (숨긴 친구 추천)
+CREATE TABLE friend_recommendation_dismissals (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dismissed_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, dismissed_user_id)
+);
+
+-- 23. profile_image_history (이전 프로필 사진)
+CREATE TABLE profile_image_history (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blob_sha256 CHAR(64) NOT NULL REFERENCES blobs(sha256),
+    storage_key VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(50) NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TRIGGER profile_image_history_blob_ref_count
+    AFTER INSERT OR UPDATE OF blob_sha256 OR DELETE ON profile_image_history
+    FOR EACH ROW EXECUTE FUNCTION adjust_blob_ref_count('blob_sha256');
+
+-- 24. username_reservations (바꾸기 전 아이디. 예약 기간 동안 다른 사용자가 가져갈 수 없다)
+CREATE TABLE username_reservations (
+    username VARCHAR(50) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reserved_until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- 25. password_resets (비밀번호 재설정 토큰. 원문 대신 해시를 저장하고 한 번만 쓸 수 있다)
+CREATE TABLE password_resets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- 26. verification_attempts (인증 코드 발송과 확인 실패 기록. 번호·IP별 한도 계산용)
+CREATE TABLE verification_attempts (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(15) NOT NULL CHECK (kind IN ('send', 'verify_failed')),
+    phone VARCHAR(20) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
 -- 인덱스 생성
 CREATE INDEX idx_friends_user_id ON friends(user_id);
 CREATE INDEX idx_friends_friend_id ON friends(friend_id);
@@ -92,3 +381,23 @@ CREATE INDEX idx_messages_created_at ON messages(created_at);
 CREATE INDEX idx_phone_verifications_phone ON phone_verifications(phone);
 CREATE INDEX idx_notification_mutes_user_id ON notification_mutes(user_id);
 CREATE INDEX idx_notification_mutes_user_room ON notification_mutes(user_id, room_id);
+CREATE INDEX idx_pinned_messages_room_id ON pinned_messages(room_id);
+CREATE INDEX idx_chat_folders_user_id ON chat_folders(user_id);
+CREATE INDEX idx_push_tokens_user_id ON push_tokens(user_id);
+CREATE INDEX idx_message_files_message_id ON message_files(message_id);
+CREATE INDEX idx_uploads_expires_at ON uploads(expires_at);
+CREATE INDEX idx_blobs_unreferenced ON blobs(updated_at) WHERE ref_count = 0;
+CREATE UNIQUE INDEX idx_friend_requests_pending ON friend_requests(requester_id, recipient_id) WHERE status = 'pending';
+CREATE INDEX idx_friend_requests_recipient ON friend_requests(recipient_id, status);
+CREATE INDEX idx_user_blocks_blocked ON user_blocks(blocked_id);
+CREATE UNIQUE INDEX idx_users_phone_hash ON users(phone_hash);
+CREATE INDEX idx_user_contacts_phone_hash ON user_contacts(phone_hash);
+CREATE INDEX idx_profile_image_history_user_id ON profile_image_history(user_id, created_at);
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_verification_attempts_phone ON verification_attempts(phone, kind, created_at);
+CREATE INDEX idx_verification_attempts_ip ON verification_attempts(ip, kind, created_at);
+CREATE INDEX idx_uploads_user_id ON uploads(user_id);
+CREATE INDEX idx_message_files_blob_sha256 ON message_files(blob_sha256);
+CREATE INDEX idx_users_profile_image_sha256 ON users(profile_image_sha256) WHERE profile_image_sha256 IS NOT NULL;
+CREATE INDEX idx_users_background_image_sha256 ON users(background_image_sha256) WHERE background_image_sha256 IS NOT NULL;
+CREATE INDEX idx_profile_image_history_blob_sha256 ON profile_image_history(blob_sha256);
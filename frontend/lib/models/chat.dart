@@ -40,7 +40,7 @@ class Message {
   final String? senderName;
   final String? content;
   final String type;
-  final int? fileId;
+  final String? fileId;
   final DateTime createdAt;
 
   Message({
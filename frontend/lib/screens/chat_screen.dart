@@ -533,7 +533,7 @@ class _ChatScreenState extends State<ChatScreen> {
         a.hour == b.hour && a.minute == b.minute;
   }
 
-  String _getAuthFileUrl(int fileId) {
+  String _getAuthFileUrl(String fileId) {
     return '${ApiService.getFileUrl(fileId)}?token=${ApiService.token}';
   }
 
@@ -248,7 +248,7 @@ class ApiService {
     return jsonDecode(response.body);
   }
 
-  static String getFileUrl(int fileId) {
+  static String getFileUrl(String fileId) {
     return '${ApiConfig.baseUrl}/api/files/$fileId';
   }
 }